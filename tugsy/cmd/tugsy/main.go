@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"os"
+	"sync"
 
-	"github.com/andmarios/aislib"
 	"github.com/joemadeus/tugsy/tugsy/config"
 	"github.com/joemadeus/tugsy/tugsy/shipdata"
 	"github.com/joemadeus/tugsy/tugsy/views"
+	"github.com/joemadeus/tugsy/tugsy/weatherdata"
 	logger "github.com/sirupsen/logrus"
 	"github.com/veandco/go-sdl2/sdl"
 )
@@ -39,35 +41,113 @@ func run() int {
 	}
 	logger.SetLevel(loglevel)
 
+	wxData := weatherdata.NewWeatherData()
 	aisData := shipdata.NewAISData()
+	aisData.Weather = wxData
+	aisData.TrackCompression = shipdata.TrackCompressionFromConfig(cfg)
 
-	logger.Info("Starting the position culling loop")
-	go aisData.PrunePositions()
+	geofences, err := shipdata.GeofencesFromConfig(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("Could not load the zones from config")
+	}
+	aisData.Geofences = geofences
+	aisData.PortCalls = shipdata.NewPortCallDetector()
+	aisData.TugJobs = shipdata.NewTugJobDetector()
+	aisData.CloseQuarters = shipdata.CloseQuartersFromConfig(cfg)
 
+	// opened before the workers start so that it's closed only after they've all exited
+	historyPath := cfg.GetString("historyPath")
+	if historyPath != "" {
+		logger.Infof("Opening the history store at %s", historyPath)
+		history, err := shipdata.OpenHistoryStore(historyPath)
+		if err != nil {
+			logger.WithError(err).Fatal("Could not open the history store")
+		}
+		defer history.Close()
+		aisData.History = history
+	}
+
+	// loaded before the snapshot is restored, so that restored ships are seeded from it
+	registryPath := cfg.GetString("registryPath")
+	if registryPath != "" {
+		registry, err := shipdata.LoadVesselRegistry(registryPath)
+		if err != nil {
+			logger.WithError(err).Error("Could not load the vessel registry, starting empty")
+			registry = shipdata.NewVesselRegistry()
+		}
+		registry.Clock = aisData
+		aisData.Registry = registry
+	}
+
+	// loaded before the snapshot is restored too, since a replay router sets the clock
+	// that everything is measured against
 	logger.Info("Loading the AIS routers")
-	decoded := make(chan aislib.Message)
-	failed := make(chan aislib.FailedSentence)
-	routers, err := shipdata.RemoteAISServersFromConfig(aisData, decoded, failed, cfg)
+	routers, err := shipdata.RemoteAISServersFromConfig(aisData, cfg)
 	if err != nil {
 		logger.WithError(err).Fatal("Could not initialize the routers")
 	}
 
-	defer func() {
-		for _, r := range routers {
-			r.Stop()
+	snapshotPath := cfg.GetString("snapshotPath")
+	if snapshotPath != "" {
+		logger.Infof("Restoring AIS data from %s", snapshotPath)
+		if err := aisData.RestoreSnapshot(snapshotPath); err != nil {
+			logger.WithError(err).Error("Could not restore the snapshot, starting empty")
 		}
+	}
+
+	// every worker goroutine runs until ctx is cancelled. wait for all of them to exit
+	// before returning, so that nothing is left behind when the UI quits
+	ctx, cancel := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer func() {
+		logger.Info("Waiting for workers to exit")
+		cancel()
+		workers.Wait()
+		logger.Info("Workers exited")
 	}()
 
-	logger.Info("Starting the router maintenance loop")
-	for _, r := range routers {
-		r.Start()
+	logger.Info("Starting the position culling loop")
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		aisData.PrunePositions(ctx)
+	}()
+
+	if aisData.History != nil {
+		logger.Info("Starting the history store loop")
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			aisData.History.Run(ctx)
+		}()
 	}
 
-	logger.Info("Starting the AIS update loops")
-	for _, r := range routers {
-		go r.DecodePositions(decoded, failed)
+	if aisData.Registry != nil {
+		logger.Info("Starting the vessel registry loop")
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			aisData.Registry.SavePeriodically(ctx, registryPath, cfg.GetDuration("registryInterval"))
+		}()
 	}
 
+	if snapshotPath != "" {
+		logger.Info("Starting the snapshot loop")
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			aisData.SnapshotPeriodically(ctx, snapshotPath, cfg.GetDuration("snapshotInterval"))
+		}()
+	}
+
+	logger.Info("Starting the AIS routers")
+	supervisor := shipdata.NewSupervisor(routers, cfg.GetDuration("routerReviveInterval"))
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		supervisor.Run(ctx)
+	}()
+
 	logger.Info("Initializing INIT_EVERYTHING")
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
 		logger.WithError(err).Fatalf("failed to INIT_EVERYTHING")
@@ -105,13 +185,31 @@ func run() int {
 	}
 	logger.Info("Initialized sprites")
 
+	fontSet, err := views.NewFontSet(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("could not load fonts from config")
+	}
+	defer fontSet.Teardown()
+	logger.Info("Initialized fonts")
+
 	// create the root view element and its direct children
 	baseInfoElement, err := views.NewBaseInfoElement(cfg, renderer)
 	if err != nil {
 		logger.WithError(err).Fatal("Could not initialize BaseInfoElement")
 	}
-	allPositionsElement := views.NewAllPositionElements(spriteSet, aisData, baseInfoElement)
-	rootElement := views.NewRootElement(cfg, baseInfoElement, allPositionsElement)
+	baseInfoElement.SetDefaultContent(views.NewPortInfoElement(fontSet, cfg.GetString("portName"), cfg.GetString("portCode"), aisData.PortCalls, aisData.TugJobs))
+	allAtoNsElement := views.NewAllAtoNElements(spriteSet, fontSet, aisData, baseInfoElement)
+	allWxStationsElement := views.NewAllWxStationElements(fontSet, wxData, baseInfoElement)
+	allPositionsElement := views.NewAllPositionElements(cfg, spriteSet, fontSet, aisData, baseInfoElement)
+	allSARAircraftElement := views.NewAllSARAircraftElements(spriteSet, fontSet, aisData, baseInfoElement)
+	alertBannerElement := views.NewAlertBannerElement(fontSet, aisData)
+
+	children := []views.ParentElement{baseInfoElement}
+	if cfg.GetBool("showZones") {
+		children = append(children, views.NewZoneOverlayElement(fontSet, geofences))
+	}
+	children = append(children, allAtoNsElement, allWxStationsElement, views.NewCloseQuartersElement(aisData), allPositionsElement, allSARAircraftElement, alertBannerElement)
+	rootElement := views.NewRootElement(cfg, children...)
 	logger.Info("Initialized RootElement & children")
 
 	viewSet, err := views.ViewSetFromConfig(cfg, renderer, rootElement)
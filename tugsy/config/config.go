@@ -11,6 +11,7 @@ import (
 const (
 	resourcesDir = "/Resources"
 	spritesDir   = "/sprites"
+	fontsDir     = "/fonts"
 	osxAppDir    = "/Applications/Tugsy.app"
 	devAppDir    = "."
 )
@@ -51,6 +52,11 @@ func (config *Config) SpriteSheetPath(spritesFile string) string {
 	return config.resourcesDirectory + spritesDir + "/" + spritesFile
 }
 
+// Returns a path to a font file
+func (config *Config) FontPath(fontFile string) string {
+	return config.resourcesDirectory + fontsDir + "/" + fontFile
+}
+
 // Returns a path to the resources for a given view
 func (config *Config) ViewPath(viewName string) string {
 	return config.resourcesDirectory + "/" + viewName + "/"
@@ -0,0 +1,157 @@
+package shipdata
+
+import (
+	"fmt"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+var aidTypeNames = []string{
+	"Unspecified",
+	"Reference point",
+	"RACON",
+	"Fixed structure off shore",
+	"Spare",
+	"Light, without sectors",
+	"Light, with sectors",
+	"Leading light front",
+	"Leading light rear",
+	"Beacon, cardinal N",
+	"Beacon, cardinal E",
+	"Beacon, cardinal S",
+	"Beacon, cardinal W",
+	"Beacon, port hand",
+	"Beacon, starboard hand",
+	"Beacon, preferred channel port hand",
+	"Beacon, preferred channel starboard hand",
+	"Beacon, isolated danger",
+	"Beacon, safe water",
+	"Beacon, special mark",
+	"Cardinal mark N",
+	"Cardinal mark E",
+	"Cardinal mark S",
+	"Cardinal mark W",
+	"Port hand mark",
+	"Starboard hand mark",
+	"Preferred channel port hand",
+	"Preferred channel starboard hand",
+	"Isolated danger",
+	"Safe water",
+	"Special mark",
+	"Light vessel / LANBY / rig",
+}
+
+// AidToNavigationReport is message type 21, broadcast by buoys, lights and beacons or
+// by a shore station on their behalf (a "virtual" aid, which may have no physical
+// presence at all)
+type AidToNavigationReport struct {
+	Repeat      uint8
+	MMSI        uint32
+	AidType     uint8
+	Name        string
+	Accuracy    bool
+	Lon         float64
+	Lat         float64
+	ToBow       uint16
+	ToStern     uint16
+	ToPort      uint8
+	ToStarboard uint8
+	EPFD        uint8
+	Second      uint8
+	OffPosition bool // the aid is floating and has drifted from its charted position
+	RAIM        bool
+	Virtual     bool
+	Assigned    bool
+}
+
+func DecodeAidToNavigationReport(payload string) (AidToNavigationReport, error) {
+	p := unarmor(payload)
+	if err := p.checkLength(21, 272); err != nil {
+		return AidToNavigationReport{}, err
+	}
+
+	report := AidToNavigationReport{
+		Repeat:      uint8(p.uint(6, 2)),
+		MMSI:        p.uint(8, 30),
+		AidType:     uint8(p.uint(38, 5)),
+		Name:        p.text(43, 120),
+		Accuracy:    p.bool(163),
+		Lon:         float64(p.int(164, 28)) / 600000,
+		Lat:         float64(p.int(192, 27)) / 600000,
+		ToBow:       uint16(p.uint(219, 9)),
+		ToStern:     uint16(p.uint(228, 9)),
+		ToPort:      uint8(p.uint(237, 6)),
+		ToStarboard: uint8(p.uint(243, 6)),
+		EPFD:        uint8(p.uint(249, 4)),
+		Second:      uint8(p.uint(253, 6)),
+		RAIM:        p.bool(268),
+		Virtual:     p.bool(269),
+		Assigned:    p.bool(270),
+	}
+
+	// the off position flag is only meaningful when the time stamp is valid
+	report.OffPosition = report.Second < 60 && p.bool(259)
+
+	// names longer than 20 characters continue after the fixed fields
+	if extension := len(p) - 272; extension >= 6 && len(report.Name) == 20 {
+		report.Name += p.text(272, extension-extension%6)
+	}
+
+	return report, nil
+}
+
+// AidTypeName returns a description of the aid's type
+func (r *AidToNavigationReport) AidTypeName() string {
+	if int(r.AidType) < len(aidTypeNames) {
+		return aidTypeNames[r.AidType]
+	}
+	return fmt.Sprintf("Type %d", r.AidType)
+}
+
+type SourcedAidToNavigationReport struct {
+	AidToNavigationReport
+	SourceAndTime
+}
+
+func (aisData *AISData) UpdateAidToNavigation(report *SourcedAidToNavigationReport) {
+	aisData.Lock()
+	defer aisData.Unlock()
+	aisData.mmsiAtoNs[report.MMSI] = report
+}
+
+// AidsToNavigation returns a copy of the slice of the latest reports from every aid to
+// navigation
+func (aisData *AISData) AidsToNavigation() []*SourcedAidToNavigationReport {
+	aisData.Lock()
+	defer aisData.Unlock()
+
+	atons := make([]*SourcedAidToNavigationReport, 0, len(aisData.mmsiAtoNs))
+	for _, aton := range aisData.mmsiAtoNs {
+		atons = append(atons, aton)
+	}
+
+	return atons
+}
+
+// Returns the latest report from the aid to navigation with the given MMSI, or nil/false
+// if it hasn't been heard from
+func (aisData *AISData) AidToNavigation(mmsi uint32) (*SourcedAidToNavigationReport, bool) {
+	aisData.Lock()
+	defer aisData.Unlock()
+	aton, ok := aisData.mmsiAtoNs[mmsi]
+	return aton, ok
+}
+
+// pruneAidsToNavigation forgets the aids that haven't reported since the given time
+func (aisData *AISData) pruneAidsToNavigation(since time.Time) {
+	aisData.Lock()
+	defer aisData.Unlock()
+
+	for mmsi, aton := range aisData.mmsiAtoNs {
+		if aton.ReceivedTime().Before(since) {
+			logger.Infof("an aid to navigation has not been heard from in a while. Removing MMSI %v", mmsi)
+			delete(aisData.mmsiAtoNs, mmsi)
+		}
+	}
+}
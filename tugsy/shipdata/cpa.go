@@ -0,0 +1,261 @@
+package shipdata
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/joemadeus/tugsy/tugsy/config"
+)
+
+const (
+	CloseQuartersStarted CloseQuartersEventType = iota
+	CloseQuartersEnded
+)
+
+const (
+	defaultCloseQuartersMeters = 200
+	defaultCloseQuartersTime   = 6 * time.Minute
+	cpaMaxReportAge            = 2 * time.Minute // reports older than this are too stale to project
+	cpaMaxOtherSpeedKts        = 30              // the fastest another vessel is expected to close at, fast ferries included
+
+	// the source of alerts raised for close quarters situations
+	closeQuartersAlertSource = "cpa"
+)
+
+type CloseQuartersEventType int
+
+func (t CloseQuartersEventType) String() string {
+	switch t {
+	case CloseQuartersStarted:
+		return "started"
+	case CloseQuartersEnded:
+		return "ended"
+	default:
+		return "unknown"
+	}
+}
+
+// An Approach is where and when two vessels will be closest, assuming both hold their
+// speed and course: the closest point of approach (CPA) and the time to it (TCPA)
+type Approach struct {
+	CPAMeters float64
+	TCPA      time.Duration // negative if they're already moving apart
+
+	// where each vessel will be at the CPA
+	ALat, ALon float64
+	BLat, BLon float64
+}
+
+// ComputeApproach works out the approach of two vessels from their latest positions,
+// both dead reckoned to at. Returns false if either isn't under way with a known speed
+// and course
+func ComputeApproach(a, b Positionable, at time.Time) (Approach, bool) {
+	pa, pb := a.GetPositionReport(), b.GetPositionReport()
+	aLat, aLon, aMoving := DeadReckon(a, at, cpaMaxReportAge)
+	bLat, bLon, bMoving := DeadReckon(b, at, cpaMaxReportAge)
+	if aMoving == false || bMoving == false {
+		return Approach{}, false
+	}
+
+	// flat-earth east/north meters, relative to a
+	metersPerDegLat := earthRadiusMeters * math.Pi / 180
+	metersPerDegLon := metersPerDegLat * math.Cos(toRadians(aLat))
+	rx, ry := (bLon-aLon)*metersPerDegLon, (bLat-aLat)*metersPerDegLat
+
+	vax, vay := velocity(float64(pa.Speed), float64(pa.Course))
+	vbx, vby := velocity(float64(pb.Speed), float64(pb.Course))
+	vx, vy := vbx-vax, vby-vay
+
+	var secs float64
+	if vSq := vx*vx + vy*vy; vSq > 1e-9 {
+		secs = -(rx*vx + ry*vy) / vSq
+	}
+
+	approach := Approach{TCPA: time.Duration(secs * float64(time.Second))}
+	approach.CPAMeters = math.Hypot(rx+vx*secs, ry+vy*secs)
+	approach.ALat, approach.ALon = offsetMeters(aLat, aLon, vax*secs, vay*secs)
+	approach.BLat, approach.BLon = offsetMeters(bLat, bLon, vbx*secs, vby*secs)
+	return approach, true
+}
+
+// velocity returns the east and north components of a speed and course, in meters per
+// second
+func velocity(speedKts, courseDeg float64) (float64, float64) {
+	v := speedKts * metersPerSecPerKt
+	return v * math.Sin(toRadians(courseDeg)), v * math.Cos(toRadians(courseDeg))
+}
+
+// An Encounter is two vessels in a close quarters situation: they'll come within the
+// CPA threshold inside the TCPA threshold. A is always the lower MMSI
+type Encounter struct {
+	A, B     uint32
+	Approach Approach
+	Since    time.Time
+	Updated  time.Time
+}
+
+type CloseQuartersEvent struct {
+	Type      CloseQuartersEventType
+	Encounter Encounter
+}
+
+func (e CloseQuartersEvent) String() string {
+	return fmt.Sprintf("close quarters between MMSI %d and %d %s", e.Encounter.A, e.Encounter.B, e.Type)
+}
+
+type encounterKey struct {
+	a, b uint32
+}
+
+// CloseQuarters computes the approach of every pair of moving vessels as their positions
+// arrive, keeping track of the encounters that are within its thresholds and sending an
+// event to every subscriber when one starts or ends
+type CloseQuarters struct {
+	sync.Mutex
+
+	CPAMeters float64
+	TCPA      time.Duration
+
+	encounters  map[encounterKey]*Encounter
+	subscribers subscriberList
+}
+
+func NewCloseQuarters() *CloseQuarters {
+	return &CloseQuarters{
+		CPAMeters:   defaultCloseQuartersMeters,
+		TCPA:        defaultCloseQuartersTime,
+		encounters:  make(map[encounterKey]*Encounter),
+		subscribers: subscriberList{kind: "close quarters"},
+	}
+}
+
+// CloseQuartersFromConfig reads the closeQuartersMeters and closeQuartersTime keys,
+// using the defaults for any that aren't set
+func CloseQuartersFromConfig(cfg *config.Config) *CloseQuarters {
+	cq := NewCloseQuarters()
+	if cfg.IsSet("closeQuartersMeters") {
+		cq.CPAMeters = cfg.GetFloat64("closeQuartersMeters")
+	}
+	if cfg.IsSet("closeQuartersTime") {
+		cq.TCPA = cfg.GetDuration("closeQuartersTime")
+	}
+	return cq
+}
+
+// Subscribe returns a channel that receives encounters as they start and end, with room
+// for buffer events
+func (cq *CloseQuarters) Subscribe(buffer int) <-chan CloseQuartersEvent {
+	cq.Lock()
+	defer cq.Unlock()
+
+	events := make(chan CloseQuartersEvent, buffer)
+	cq.subscribers.add(events)
+	return events
+}
+
+// Unsubscribe stops sending events to a channel returned by Subscribe, and closes it
+func (cq *CloseQuarters) Unsubscribe(events <-chan CloseQuartersEvent) {
+	cq.Lock()
+	defer cq.Unlock()
+	cq.subscribers.remove(events)
+}
+
+// Encounters returns the active encounters, oldest first
+func (cq *CloseQuarters) Encounters() []Encounter {
+	cq.Lock()
+	defer cq.Unlock()
+
+	encounters := make([]Encounter, 0, len(cq.encounters))
+	for _, e := range cq.encounters {
+		encounters = append(encounters, *e)
+	}
+	sort.Slice(encounters, func(i, j int) bool { return encounters[i].Since.Before(encounters[j].Since) })
+	return encounters
+}
+
+// searchMeters returns how far away another vessel's latest position can be and still
+// come within the CPA threshold inside the TCPA threshold: as far as both can travel in
+// that time, plus the age of the other's report, with the other at no more than
+// cpaMaxOtherSpeedKts
+func (cq *CloseQuarters) searchMeters(position Positionable) float64 {
+	speed := float64(position.GetPositionReport().Speed)
+	if speed >= 102.3 {
+		return cq.CPAMeters
+	}
+	window := cq.TCPA + cpaMaxReportAge
+	return cq.CPAMeters + (speed+cpaMaxOtherSpeedKts)*metersPerSecPerKt*window.Seconds()
+}
+
+// evaluate computes the approach of the vessel with a new position to every other vessel
+// with a recent one, except those skip returns true for. others need only be those within
+// searchMeters of the position. Returns the events it causes after sending them to the
+// subscribers
+func (cq *CloseQuarters) evaluate(position Positionable, others []*ShipHistory, skip func(a, b uint32) bool) []CloseQuartersEvent {
+	mmsi := position.GetPositionReport().MMSI
+	at := position.ReceivedTime()
+
+	var events []CloseQuartersEvent
+	for _, other := range others {
+		if other.MMSI == mmsi || (skip != nil && skip(mmsi, other.MMSI)) {
+			continue
+		}
+
+		otherPositions := other.Positions()
+		if len(otherPositions) == 0 {
+			continue
+		}
+		otherPosition := otherPositions[len(otherPositions)-1]
+		if at.Sub(otherPosition.ReceivedTime()) > cpaMaxReportAge {
+			continue
+		}
+
+		approach, ok := ComputeApproach(position, otherPosition, at)
+		near := ok && approach.CPAMeters <= cq.CPAMeters && approach.TCPA >= 0 && approach.TCPA <= cq.TCPA
+
+		key := encounterKey{mmsi, other.MMSI}
+		if key.a > key.b {
+			key = encounterKey{other.MMSI, mmsi}
+			approach.ALat, approach.ALon, approach.BLat, approach.BLon = approach.BLat, approach.BLon, approach.ALat, approach.ALon
+		}
+
+		cq.Lock()
+		encounter, active := cq.encounters[key]
+		switch {
+		case near && active == false:
+			encounter = &Encounter{A: key.a, B: key.b, Approach: approach, Since: at, Updated: at}
+			cq.encounters[key] = encounter
+			events = append(events, cq.publish(CloseQuartersEvent{CloseQuartersStarted, *encounter}))
+		case near:
+			encounter.Approach, encounter.Updated = approach, at
+		case active:
+			delete(cq.encounters, key)
+			events = append(events, cq.publish(CloseQuartersEvent{CloseQuartersEnded, *encounter}))
+		}
+		cq.Unlock()
+	}
+
+	return events
+}
+
+// expire ends the encounters that haven't been updated recently, because one of the
+// vessels has stopped reporting
+func (cq *CloseQuarters) expire(now time.Time) {
+	cq.Lock()
+	defer cq.Unlock()
+
+	for key, encounter := range cq.encounters {
+		if now.Sub(encounter.Updated) > cpaMaxReportAge {
+			delete(cq.encounters, key)
+			cq.publish(CloseQuartersEvent{CloseQuartersEnded, *encounter})
+		}
+	}
+}
+
+// publish sends an event to every subscriber, returning it. Called with cq locked
+func (cq *CloseQuarters) publish(event CloseQuartersEvent) CloseQuartersEvent {
+	cq.subscribers.publish(event)
+	return event
+}
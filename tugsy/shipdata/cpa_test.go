@@ -0,0 +1,64 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andmarios/aislib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseQuarters(t *testing.T) {
+	now := time.Now()
+	position := func(mmsi uint32, lat, lon float64, course float32, at time.Time) *SourcedClassAPositionReport {
+		return &SourcedClassAPositionReport{
+			aislib.ClassAPositionReport{PositionReport: aislib.PositionReport{MMSI: mmsi, Lat: lat, Lon: lon, Speed: 10, Course: course}},
+			SourceAndTime{receivedTime: at},
+		}
+	}
+
+	// head on at a combined 20 knots, 1850m apart north to south and 50m east to west.
+	// they pass 50m apart in 1850 / (20 * 0.514) = 180 seconds
+	north := position(2, 41.77+1850/111195.0, -71.38+50/82850.0, 180, now)
+	south := position(1, 41.77, -71.38, 0, now)
+
+	approach, ok := ComputeApproach(south, north, now)
+	assert.True(t, ok)
+	assert.InDelta(t, 50, approach.CPAMeters, 1)
+	assert.InDelta(t, 180, approach.TCPA.Seconds(), 1)
+	assert.InDelta(t, approach.ALat, approach.BLat, 0.00001)
+
+	aisData := NewAISData()
+	aisData.CloseQuarters = NewCloseQuarters()
+	events := aisData.CloseQuarters.Subscribe(10)
+	aisData.AddPosition(south)
+	aisData.AddPosition(north)
+
+	encounters := aisData.CloseQuarters.Encounters()
+	assert.Equal(t, 1, len(encounters))
+	assert.Equal(t, uint32(1), encounters[0].A)
+	assert.Equal(t, uint32(2), encounters[0].B)
+	assert.Equal(t, 1, len(aisData.Alerts()))
+
+	// once they're past each other, it's over
+	aisData.AddPosition(position(1, 41.77+2000/111195.0, -71.38, 0, now.Add(200*time.Second)))
+	aisData.AddPosition(position(2, 41.77-150/111195.0, -71.38+50/82850.0, 180, now.Add(201*time.Second)))
+	assert.Equal(t, 0, len(aisData.CloseQuarters.Encounters()))
+
+	for _, expected := range []CloseQuartersEventType{CloseQuartersStarted, CloseQuartersEnded} {
+		select {
+		case event := <-events:
+			assert.Equal(t, expected, event.Type)
+		default:
+			t.Fatalf("expected a %s event", expected)
+		}
+	}
+	aisData.CloseQuarters.Unsubscribe(events)
+
+	// moored vessels have no approach
+	_, ok = ComputeApproach(south, &SourcedClassAPositionReport{
+		aislib.ClassAPositionReport{PositionReport: aislib.PositionReport{MMSI: 3, Lat: 41.78, Lon: -71.38, Speed: 0, Course: 0}, Status: navStatusMoored},
+		SourceAndTime{receivedTime: now},
+	}, now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,73 @@
+package shipdata
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	minProjectedSpeedKts = 0.5 // slower than this is drift, not way
+	maxReliableTurn      = 720 // degrees per minute. aislib reports "not available" and "turning fast" beyond this
+
+	navStatusAtAnchor = 1
+	navStatusMoored   = 5
+	navStatusAground  = 6
+)
+
+// DeadReckon projects the position forward from when it was reported to at, using its
+// speed and course over ground and, for Class A vessels, its rate of turn. The projection
+// runs for no longer than horizon. Returns the reported position and false if it can't
+// or shouldn't be projected: the vessel is moored, anchored, aground or barely moving, or
+// didn't report its speed or course
+func DeadReckon(position Positionable, at time.Time, horizon time.Duration) (lat, lon float64, projected bool) {
+	report := position.GetPositionReport()
+	lat, lon = report.Lat, report.Lon
+
+	elapsed := at.Sub(position.ReceivedTime())
+	if elapsed > horizon {
+		elapsed = horizon
+	} else if elapsed < 0 {
+		elapsed = 0
+	}
+	if report.Speed >= 102.3 || report.Speed < minProjectedSpeedKts || report.Course >= 360 {
+		return lat, lon, false
+	}
+
+	var turnDegPerMin float64
+	if classA, ok := position.(*SourcedClassAPositionReport); ok {
+		switch classA.Status {
+		case navStatusAtAnchor, navStatusMoored, navStatusAground:
+			return lat, lon, false
+		}
+		if math.Abs(float64(classA.Turn)) < maxReliableTurn {
+			turnDegPerMin = float64(classA.Turn)
+		}
+	}
+
+	east, north := travelMeters(float64(report.Speed), float64(report.Course), turnDegPerMin, elapsed.Seconds())
+	lat, lon = offsetMeters(lat, lon, east, north)
+	return lat, lon, true
+}
+
+// travelMeters returns how far east and north a vessel travels in secs at a constant
+// speed, starting on the given course and turning at a constant rate
+func travelMeters(speedKts, courseDeg, turnDegPerMin, secs float64) (east, north float64) {
+	v := speedKts * metersPerSecPerKt
+	c := toRadians(courseDeg)
+	w := toRadians(turnDegPerMin) / 60
+
+	if math.Abs(w) < 1e-6 {
+		return v * secs * math.Sin(c), v * secs * math.Cos(c)
+	}
+
+	// along the arc of a circle of radius v/w
+	r := v / w
+	return r * (math.Cos(c) - math.Cos(c+w*secs)), r * (math.Sin(c+w*secs) - math.Sin(c))
+}
+
+// offsetMeters moves a position by the given distances, which must be small enough for
+// the earth to be flat over them
+func offsetMeters(lat, lon, east, north float64) (float64, float64) {
+	metersPerDegLat := earthRadiusMeters * math.Pi / 180
+	return lat + north/metersPerDegLat, lon + east/(metersPerDegLat*math.Cos(toRadians(lat)))
+}
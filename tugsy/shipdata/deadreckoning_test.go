@@ -0,0 +1,44 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andmarios/aislib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadReckon(t *testing.T) {
+	now := time.Now()
+	report := func(speed, course, turn float32, status uint8) *SourcedClassAPositionReport {
+		return &SourcedClassAPositionReport{
+			aislib.ClassAPositionReport{
+				PositionReport: aislib.PositionReport{Lat: 41.8, Lon: -71.39, Speed: speed, Course: course},
+				Status:         status,
+				Turn:           turn,
+			},
+			SourceAndTime{receivedTime: now},
+		}
+	}
+
+	// ten knots due north for a minute is about 308 meters, or 0.00278 degrees
+	lat, lon, ok := DeadReckon(report(10, 0, 0, 0), now.Add(time.Minute), 2*time.Minute)
+	assert.True(t, ok)
+	assert.InDelta(t, 41.80277, lat, 0.00001)
+	assert.InDelta(t, -71.39, lon, 0.00001)
+
+	// the projection stops at the horizon
+	lat, _, _ = DeadReckon(report(10, 0, 0, 0), now.Add(time.Hour), 2*time.Minute)
+	assert.InDelta(t, 41.80555, lat, 0.00001)
+
+	// turning to starboard at 90 degrees a minute for a minute ends up east of north
+	lat, lon, _ = DeadReckon(report(10, 0, 90, 0), now.Add(time.Minute), 2*time.Minute)
+	assert.True(t, lat > 41.8 && lat < 41.80277)
+	assert.True(t, lon > -71.39)
+
+	// moored vessels and those without a course stay put
+	_, _, ok = DeadReckon(report(10, 0, 0, navStatusMoored), now.Add(time.Minute), 2*time.Minute)
+	assert.False(t, ok)
+	_, _, ok = DeadReckon(report(10, 360, 0, 0), now.Add(time.Minute), 2*time.Minute)
+	assert.False(t, ok)
+}
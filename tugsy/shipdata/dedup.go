@@ -0,0 +1,166 @@
+package shipdata
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultDedupWindow = 10 * time.Second
+
+	// position reports from the same MMSI with the same timestamp second that are closer
+	// than this are taken to be the same report, heard twice
+	nearIdenticalPositionMeters = 20.0
+)
+
+// heardBy records every source that received a message. The first source is the one the
+// message is attributed to
+type heardBy struct {
+	sync.Mutex
+	first   string // never changes, so it's safe to read without the lock
+	sources []string
+}
+
+func newHeardBy(source string) *heardBy {
+	return &heardBy{first: source, sources: []string{source}}
+}
+
+func (h *heardBy) add(source string) {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, s := range h.sources {
+		if s == source {
+			return
+		}
+	}
+	h.sources = append(h.sources, source)
+}
+
+func (h *heardBy) others() []string {
+	h.Lock()
+	defer h.Unlock()
+
+	others := make([]string, len(h.sources)-1)
+	copy(others, h.sources[1:])
+	return others
+}
+
+type heardPayload struct {
+	heard *heardBy
+	at    time.Time
+}
+
+type heardPosition struct {
+	heard  *heardBy
+	at     time.Time
+	report *dedupPosition
+}
+
+// dedupPosition is the part of a position report the Deduplicator compares
+type dedupPosition struct {
+	Lat, Lon float64
+	Second   uint8
+}
+
+// A Deduplicator recognises the same AIS message arriving from more than one
+// RemoteAISServer, e.g. when the house radio and an internet feed both hear a vessel. The
+// first arrival is kept; later arrivals from other sources within Window are dropped and
+// recorded against the first. Messages repeated by the same source are never dropped,
+// since a moored vessel will legitimately send the same payload over and over
+type Deduplicator struct {
+	sync.Mutex
+
+	Window time.Duration
+
+	payloads  map[string]*heardPayload
+	positions map[uint32][]*heardPosition
+	lastSweep time.Time
+}
+
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+
+	return &Deduplicator{
+		Window:    window,
+		payloads:  make(map[string]*heardPayload),
+		positions: make(map[uint32][]*heardPosition),
+	}
+}
+
+// checkPayload returns the heardBy for the given raw payload and whether it's a duplicate
+// of a payload already heard from another source
+func (d *Deduplicator) checkPayload(payload, source string, at time.Time) (*heardBy, bool) {
+	d.Lock()
+	defer d.Unlock()
+	d.sweep(at)
+
+	if seen, ok := d.payloads[payload]; ok && at.Sub(seen.at) <= d.Window {
+		if seen.heard.first != source {
+			seen.heard.add(source)
+			return seen.heard, true
+		}
+	}
+
+	heard := newHeardBy(source)
+	d.payloads[payload] = &heardPayload{heard: heard, at: at}
+	return heard, false
+}
+
+// checkPosition tests a decoded position report against the recent reports for the same
+// MMSI, catching the reports that were re-encoded somewhere along the way and so don't
+// have identical payloads. It returns true if the report is a duplicate, in which case
+// the source has been recorded against the original. Otherwise heard is remembered as
+// belonging to this report
+func (d *Deduplicator) checkPosition(mmsi uint32, report *dedupPosition, heard *heardBy, at time.Time) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	source := heard.first
+	for _, seen := range d.positions[mmsi] {
+		if at.Sub(seen.at) > d.Window || seen.heard.first == source {
+			continue
+		}
+
+		if seen.report.Second == report.Second &&
+			distanceMeters(seen.report.Lat, seen.report.Lon, report.Lat, report.Lon) < nearIdenticalPositionMeters {
+			seen.heard.add(source)
+			return true
+		}
+	}
+
+	d.positions[mmsi] = append(d.positions[mmsi], &heardPosition{heard: heard, at: at, report: report})
+	return false
+}
+
+// sweep forgets everything older than the window. It's only run once per window, since
+// there's no harm in holding onto a few stale entries for a little while
+func (d *Deduplicator) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.Window {
+		return
+	}
+	d.lastSweep = now
+
+	for payload, seen := range d.payloads {
+		if now.Sub(seen.at) > d.Window {
+			delete(d.payloads, payload)
+		}
+	}
+
+	for mmsi, seens := range d.positions {
+		var kept []*heardPosition
+		for _, seen := range seens {
+			if now.Sub(seen.at) <= d.Window {
+				kept = append(kept, seen)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(d.positions, mmsi)
+		} else {
+			d.positions[mmsi] = kept
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicatePayloads(t *testing.T) {
+	now := time.Now()
+	d := NewDeduplicator(10 * time.Second)
+
+	heard, dup := d.checkPayload("payload", "radio", now)
+	assert.False(t, dup)
+
+	// the same source repeating itself isn't a duplicate
+	_, dup = d.checkPayload("payload", "radio", now.Add(time.Second))
+	assert.False(t, dup)
+
+	_, dup = d.checkPayload("payload", "internet", now.Add(2*time.Second))
+	assert.True(t, dup)
+
+	// the second arrival from "radio" replaced the first, so it's the one that was also
+	// heard by "internet"
+	st := SourceAndTime{sourceName: "radio", heard: heard}
+	assert.Equal(t, 0, len(st.AlsoHeardBy()))
+
+	heard, dup = d.checkPayload("payload", "radio", now.Add(20*time.Second))
+	assert.False(t, dup)
+	_, dup = d.checkPayload("payload", "internet", now.Add(21*time.Second))
+	assert.True(t, dup)
+	st = SourceAndTime{sourceName: "radio", heard: heard}
+	assert.Equal(t, []string{"internet"}, st.AlsoHeardBy())
+
+	// outside the window
+	_, dup = d.checkPayload("payload", "internet", now.Add(40*time.Second))
+	assert.False(t, dup)
+}
+
+func TestNearIdenticalPositions(t *testing.T) {
+	now := time.Now()
+	d := NewDeduplicator(10 * time.Second)
+
+	radio := newHeardBy("radio")
+	assert.False(t, d.checkPosition(1, &dedupPosition{Lat: 41.8, Lon: -71.4, Second: 12}, radio, now))
+
+	// a few meters off, same timestamp, different source
+	assert.True(t, d.checkPosition(1, &dedupPosition{Lat: 41.80001, Lon: -71.4, Second: 12}, newHeardBy("internet"), now))
+	assert.Equal(t, []string{"internet"}, radio.others())
+
+	// different timestamp
+	assert.False(t, d.checkPosition(1, &dedupPosition{Lat: 41.8, Lon: -71.4, Second: 13}, newHeardBy("internet"), now))
+
+	// different MMSI
+	assert.False(t, d.checkPosition(2, &dedupPosition{Lat: 41.8, Lon: -71.4, Second: 12}, newHeardBy("internet"), now))
+}
@@ -0,0 +1,294 @@
+package shipdata
+
+import (
+	"strings"
+)
+
+// A Port is a location in the UN/LOCODE table. Ports that a destination names but that
+// aren't in the table have an empty LOCODE, and the name they were given as
+type Port struct {
+	LOCODE string // the country's ISO code then three characters for the place: "USPVD"
+	Name   string
+}
+
+// Resolved returns true if the port was found in the UN/LOCODE table
+func (p Port) Resolved() bool {
+	return p.LOCODE != ""
+}
+
+// Country returns the ISO code of the port's country, or "" if it isn't resolved
+func (p Port) Country() string {
+	if len(p.LOCODE) < 2 {
+		return ""
+	}
+	return p.LOCODE[:2]
+}
+
+// A Destination is the free text a vessel sends as its destination, normalized. Origin
+// is set when the text also names where the vessel sailed from, as in "USPVD>USNYC", and
+// Port is empty when the text is
+type Destination struct {
+	Raw    string
+	Origin Port
+	Port   Port
+}
+
+func (d Destination) String() string {
+	if d.Origin.Name == "" {
+		return d.Port.Name
+	}
+	return d.Origin.Name + " > " + d.Port.Name
+}
+
+// LookupPort returns the port with the given UN/LOCODE, and true if it's in the table
+func LookupPort(locode string) (Port, bool) {
+	entry, ok := locodes[locode]
+	if ok == false {
+		return Port{}, false
+	}
+	return Port{LOCODE: locode, Name: entry.name}, true
+}
+
+// NormalizeDestination parses a vessel's destination. Masters write these any number of
+// ways: by name ("PROVIDENCE", "PROVIDENCE RI"), by UN/LOCODE with or without the space
+// ("US PVD", "USPVD"), and with an origin in front ("USPVD>USNYC", "PROVIDENCE=>NEW
+// YORK"). AIS pads the field with '@', which is dropped
+func NormalizeDestination(raw string) Destination {
+	d := Destination{Raw: raw}
+
+	text := strings.ToUpper(strings.Replace(raw, "@", " ", -1))
+	for _, arrow := range []string{"=>", "->", "<>"} {
+		text = strings.Replace(text, arrow, ">", -1)
+	}
+
+	var parts []string
+	for _, part := range strings.Split(text, ">") {
+		if part = strings.Join(strings.Fields(part), " "); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	switch len(parts) {
+	case 0:
+		return d
+	case 1:
+		d.Port = resolvePort(parts[0])
+	default:
+		d.Origin = resolvePort(parts[0])
+		d.Port = resolvePort(parts[len(parts)-1])
+	}
+	return d
+}
+
+// resolvePort looks a single place up in the table, first as a UN/LOCODE, then by name.
+// A name may be followed by qualifiers: a state, province or country code, which the
+// port has to be in, or words like "ANCH" that say nothing about which port it is. A
+// name that's shared by more than one port in the table needs a qualifier to pick one.
+// Returns an unresolved Port with the text as its name if nothing matches
+func resolvePort(text string) Port {
+	compact := strings.NewReplacer(" ", "", "-", "", ".", "", ",", "").Replace(text)
+	if p, ok := LookupPort(compact); ok {
+		return p
+	}
+
+	words := strings.Fields(punctuation.Replace(text))
+	if len(words) > 0 {
+		if p, ok := LookupPort(words[0]); ok {
+			return p
+		}
+	}
+	if len(words) > 2 && words[0] == "PORT" && words[1] == "OF" {
+		words = words[2:]
+	}
+
+	// the whole name first, in case a qualifier is part of it, then without qualifiers
+	var regions []string
+	for n := len(words); n > 0; n-- {
+		if locode, ok := matchPortName(strings.Join(words[:n], " "), regions); ok {
+			p, _ := LookupPort(locode)
+			return p
+		}
+
+		last := words[n-1]
+		switch {
+		case nonPlaceQualifiers[last]:
+		case last == "USA":
+			regions = append(regions, "US")
+		case len(last) == 2:
+			regions = append(regions, last)
+		default:
+			return Port{Name: text}
+		}
+	}
+
+	return Port{Name: text}
+}
+
+// matchPortName returns the one port with the name that's in every one of the regions,
+// each a country or subdivision code
+func matchPortName(name string, regions []string) (string, bool) {
+	var match string
+	for _, locode := range portNames[name] {
+		entry := locodes[locode]
+		inRegions := true
+		for _, region := range regions {
+			if region != locode[:2] && region != entry.subdivision {
+				inRegions = false
+			}
+		}
+		if inRegions == false {
+			continue
+		}
+		if match != "" {
+			return "", false
+		}
+		match = locode
+	}
+	return match, match != ""
+}
+
+// nonPlaceQualifiers are the words that follow a port's name without narrowing down
+// which port it is
+var nonPlaceQualifiers = map[string]bool{
+	"ANCH":      true,
+	"ANCHOR":    true,
+	"ANCHORAGE": true,
+	"PILOT":     true,
+	"PILOTS":    true,
+	"HARBOR":    true,
+	"HARBOUR":   true,
+}
+
+// punctuation is dropped from names before they're looked up, so that "ST. JOHN'S" is
+// "ST JOHNS"
+var punctuation = strings.NewReplacer(",", " ", ".", " ", "'", "")
+
+// portNames indexes the table by upper case name, plus the aliases
+var portNames = indexLocodes()
+
+func indexLocodes() map[string][]string {
+	names := make(map[string][]string, len(locodes)+len(portAliases))
+	for locode, entry := range locodes {
+		name := strings.Join(strings.Fields(punctuation.Replace(strings.ToUpper(entry.name))), " ")
+		names[name] = append(names[name], locode)
+	}
+	for alias, locode := range portAliases {
+		names[alias] = append(names[alias], locode)
+	}
+	return names
+}
+
+// portAliases are the other names vessels commonly give for ports in the table
+var portAliases = map[string]string{
+	"NY":              "USNYC",
+	"NYC":             "USNYC",
+	"NEWYORK":         "USNYC",
+	"PT EVERGLADES":   "USPEF",
+	"FT LAUDERDALE":   "USPEF",
+	"STHAMPTON":       "GBSOU",
+	"HAMBURGO":        "DEHAM",
+	"ANTWERPEN":       "BEANR",
+	"ANVERS":          "BEANR",
+	"GENOVA":          "ITGOA",
+	"ST JOHN":         "CASJB",
+	"TANGIER MED":     "MAPTM",
+	"TANGER MED":      "MAPTM",
+	"PROV":            "USPVD",
+	"NARRAGANSETT BY": "USPVD",
+}
+
+type locodeEntry struct {
+	name        string
+	subdivision string // the state or province, for the US and Canada
+}
+
+// locodes is a curated subset of the UN/LOCODE table: the ports that vessels calling in
+// and around Narragansett Bay commonly give as their destinations or origins. It isn't
+// the whole list, so a destination that isn't in it stays unresolved rather than being
+// matched to the nearest thing that is
+var locodes = map[string]locodeEntry{
+	// New England and New York
+	"USPVD": {"Providence", "RI"},
+	"USNPT": {"Newport", "RI"},
+	"USNHV": {"New Haven", "CT"},
+	"USBDR": {"Bridgeport", "CT"},
+	"USBOS": {"Boston", "MA"},
+	"USPSM": {"Portsmouth", "NH"},
+	"USPWM": {"Portland", "ME"},
+	"USNYC": {"New York", "NY"},
+	"USEWR": {"Newark", "NJ"},
+	"USALB": {"Albany", "NY"},
+
+	// the rest of the United States and Puerto Rico
+	"USPHL": {"Philadelphia", "PA"},
+	"USILG": {"Wilmington", "DE"},
+	"USBAL": {"Baltimore", "MD"},
+	"USNNS": {"Newport News", "VA"},
+	"USORF": {"Norfolk", "VA"},
+	"USILM": {"Wilmington", "NC"},
+	"USCHS": {"Charleston", "SC"},
+	"USSAV": {"Savannah", "GA"},
+	"USJAX": {"Jacksonville", "FL"},
+	"USPEF": {"Port Everglades", "FL"},
+	"USMIA": {"Miami", "FL"},
+	"USTPA": {"Tampa", "FL"},
+	"USMOB": {"Mobile", "AL"},
+	"USMSY": {"New Orleans", "LA"},
+	"USHOU": {"Houston", "TX"},
+	"USCRP": {"Corpus Christi", "TX"},
+	"USLAX": {"Los Angeles", "CA"},
+	"USLGB": {"Long Beach", "CA"},
+	"USOAK": {"Oakland", "CA"},
+	"USSEA": {"Seattle", "WA"},
+	"USTIW": {"Tacoma", "WA"},
+	"PRSJU": {"San Juan", ""},
+
+	// Canada
+	"CAHAL": {"Halifax", "NS"},
+	"CASJB": {"Saint John", "NB"},
+	"CASJF": {"St. John's", "NL"},
+	"CAMTR": {"Montreal", "QC"},
+	"CAQUE": {"Quebec", "QC"},
+	"CAVAN": {"Vancouver", "BC"},
+
+	// Central America, the Caribbean and South America
+	"BSFPO": {"Freeport", ""},
+	"JMKIN": {"Kingston", ""},
+	"MXVER": {"Veracruz", ""},
+	"MXATM": {"Altamira", ""},
+	"PABLB": {"Balboa", ""},
+	"PAONX": {"Colon", ""},
+	"COCTG": {"Cartagena", ""},
+	"BRSSZ": {"Santos", ""},
+	"BRRIO": {"Rio de Janeiro", ""},
+	"ARBUE": {"Buenos Aires", ""},
+
+	// Europe and Africa
+	"NLRTM": {"Rotterdam", ""},
+	"NLAMS": {"Amsterdam", ""},
+	"BEANR": {"Antwerp", ""},
+	"DEHAM": {"Hamburg", ""},
+	"DEBRV": {"Bremerhaven", ""},
+	"GBFXT": {"Felixstowe", ""},
+	"GBSOU": {"Southampton", ""},
+	"GBLIV": {"Liverpool", ""},
+	"FRLEH": {"Le Havre", ""},
+	"ESALG": {"Algeciras", ""},
+	"ESVLC": {"Valencia", ""},
+	"ESBCN": {"Barcelona", ""},
+	"PTSIE": {"Sines", ""},
+	"PTLIS": {"Lisbon", ""},
+	"ITGOA": {"Genoa", ""},
+	"GRPIR": {"Piraeus", ""},
+	"MAPTM": {"Tanger Med", ""},
+
+	// Asia and the Middle East
+	"AEJEA": {"Jebel Ali", ""},
+	"SGSIN": {"Singapore", ""},
+	"CNSHA": {"Shanghai", ""},
+	"CNNGB": {"Ningbo", ""},
+	"HKHKG": {"Hong Kong", ""},
+	"KRPUS": {"Busan", ""},
+	"JPTYO": {"Tokyo", ""},
+	"JPYOK": {"Yokohama", ""},
+}
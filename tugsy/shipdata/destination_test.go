@@ -0,0 +1,56 @@
+package shipdata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeDestination(t *testing.T) {
+	tests := []struct {
+		raw    string
+		origin string
+		port   string
+	}{
+		{"PROVIDENCE", "", "USPVD"},
+		{"PROVIDENCE RI@@@@@@", "", "USPVD"},
+		{"US PVD", "", "USPVD"},
+		{"USPVD", "", "USPVD"},
+		{"uspvd", "", "USPVD"},
+		{"PVD", "", ""},
+		{"NEWPORT", "", "USNPT"},
+		{"NEWPORT NEWS", "", "USNNS"},
+		{"NEWPORT NEWS VA", "", "USNNS"},
+		{"FREEPORT TX", "", ""},
+		{"PORTLAND OR", "", ""},
+		{"PORTLAND ME", "", "USPWM"},
+		{"BOSTON ANCH", "", "USBOS"},
+		{"WILMINGTON", "", ""},
+		{"WILMINGTON DE", "", "USILG"},
+		{"WILMINGTON NC USA", "", "USILM"},
+		{"HAMBURG DE", "", "DEHAM"},
+		{"NEWPORT BEACH", "", ""},
+		{"USPVD>USNYC", "USPVD", "USNYC"},
+		{"US PVD => US NYC", "USPVD", "USNYC"},
+		{"NEW YORK,NY", "", "USNYC"},
+		{"PORT OF ROTTERDAM", "", "NLRTM"},
+		{"ST. JOHN'S", "", "CASJF"},
+	}
+
+	for _, test := range tests {
+		d := NormalizeDestination(test.raw)
+		assert.Equal(t, test.origin, d.Origin.LOCODE, "origin of %q", test.raw)
+		assert.Equal(t, test.port, d.Port.LOCODE, "port of %q", test.raw)
+	}
+
+	d := NormalizeDestination("USPVD>USNYC")
+	assert.Equal(t, "Providence > New York", d.String())
+	assert.Equal(t, "US", d.Port.Country())
+
+	d = NormalizeDestination("FOR ORDERS")
+	assert.False(t, d.Port.Resolved())
+	assert.Equal(t, "FOR ORDERS", d.Port.Name)
+
+	d = NormalizeDestination("@@@@@@@@@@@@@@@@@@@@")
+	assert.Equal(t, Destination{Raw: "@@@@@@@@@@@@@@@@@@@@"}, d)
+}
@@ -0,0 +1,128 @@
+package shipdata
+
+import (
+	"fmt"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	VesselAdded ChangeType = iota
+	PositionAdded
+	StaticDataChanged
+	VesselPruned
+)
+
+const (
+	changeEventBuffer = 1024
+)
+
+type ChangeType int
+
+func (t ChangeType) String() string {
+	switch t {
+	case VesselAdded:
+		return "vessel added"
+	case PositionAdded:
+		return "position added"
+	case StaticDataChanged:
+		return "static data changed"
+	case VesselPruned:
+		return "vessel pruned"
+	default:
+		return "unknown"
+	}
+}
+
+// A ChangeEvent is a change to the AIS data. Position is set for PositionAdded events
+// and VoyageData for StaticDataChanged ones. At is when the change happened: when the
+// report causing it was received or, for pruning, when the vessel was removed
+type ChangeEvent struct {
+	Type       ChangeType
+	MMSI       uint32
+	At         time.Time
+	Position   Positionable
+	VoyageData *SourcedStaticVoyageData
+}
+
+func (e ChangeEvent) String() string {
+	return fmt.Sprintf("MMSI %d %s", e.MMSI, e.Type)
+}
+
+// A ChangeFilter selects the events a subscriber receives. A nil filter selects them all
+type ChangeFilter func(ChangeEvent) bool
+
+// ChangeTypes returns a filter selecting events of the given types
+func ChangeTypes(types ...ChangeType) ChangeFilter {
+	return func(e ChangeEvent) bool {
+		for _, t := range types {
+			if e.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ChangesTo returns a filter selecting events for the given vessels
+func ChangesTo(mmsis ...uint32) ChangeFilter {
+	return func(e ChangeEvent) bool {
+		for _, mmsi := range mmsis {
+			if e.MMSI == mmsi {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type changeSubscriber struct {
+	filter ChangeFilter
+	events chan ChangeEvent
+}
+
+// Subscribe returns a channel that receives every change the filter selects from now on.
+// Subscribers must keep up: events are dropped rather than wait for one that's fallen a
+// thousand or so behind
+func (aisData *AISData) Subscribe(filter ChangeFilter) <-chan ChangeEvent {
+	aisData.subscribersLock.Lock()
+	defer aisData.subscribersLock.Unlock()
+
+	events := make(chan ChangeEvent, changeEventBuffer)
+	aisData.subscribers = append(aisData.subscribers, changeSubscriber{filter, events})
+	return events
+}
+
+// Unsubscribe stops sending changes to a channel returned by Subscribe, and closes it
+func (aisData *AISData) Unsubscribe(events <-chan ChangeEvent) {
+	aisData.subscribersLock.Lock()
+	defer aisData.subscribersLock.Unlock()
+
+	for i, sub := range aisData.subscribers {
+		if sub.events == events {
+			close(sub.events)
+			aisData.subscribers = append(aisData.subscribers[:i], aisData.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// publish sends an event to every subscriber whose filter selects it. It must not be
+// called with aisData locked, so that subscribers are free to call back into it
+func (aisData *AISData) publish(event ChangeEvent) {
+	aisData.subscribersLock.Lock()
+	defer aisData.subscribersLock.Unlock()
+
+	for _, sub := range aisData.subscribers {
+		if sub.filter != nil && sub.filter(event) == false {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			logger.Warnf("a change subscriber is falling behind, dropping %s", event)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andmarios/aislib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	aisData := NewAISData()
+	all := aisData.Subscribe(nil)
+	staticOnly := aisData.Subscribe(ChangeTypes(StaticDataChanged))
+	otherShip := aisData.Subscribe(ChangesTo(2))
+
+	now := time.Now()
+	aisData.AddPosition(positionAt(41.8, -71.39, now))
+	voyageData := &SourcedStaticVoyageData{aislib.StaticVoyageData{MMSI: 1, VesselName: "BLOCK ISLAND"}, SourceAndTime{receivedTime: now}}
+	aisData.UpdateStaticVoyageData(voyageData)
+	aisData.UpdateStaticVoyageData(voyageData) // no change, no event
+
+	assert.Equal(t, 3, len(all))
+	assert.Equal(t, VesselAdded, (<-all).Type)
+	added := <-all
+	assert.Equal(t, PositionAdded, added.Type)
+	assert.Equal(t, now, added.At)
+	assert.Equal(t, StaticDataChanged, (<-all).Type)
+
+	assert.Equal(t, 1, len(staticOnly))
+	assert.Equal(t, "BLOCK ISLAND", (<-staticOnly).VoyageData.VesselName)
+	assert.Equal(t, 0, len(otherShip))
+
+	aisData.Unsubscribe(all)
+	aisData.AddPosition(positionAt(41.8, -71.39, now.Add(time.Second)))
+	_, open := <-all
+	assert.False(t, open)
+}
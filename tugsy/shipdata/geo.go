@@ -0,0 +1,57 @@
+package shipdata
+
+import "math"
+
+const (
+	earthRadiusMeters = 6371008.8
+)
+
+// A BoundingBox is an area on the map, in decimal degrees
+type BoundingBox struct {
+	South, West, North, East float64
+}
+
+func (b BoundingBox) Contains(lat, lon float64) bool {
+	return lat >= b.South && lat <= b.North && lon >= b.West && lon <= b.East
+}
+
+// boxAround returns the box bounding the circle of radius meters around lat/lon
+func boxAround(lat, lon, meters float64) BoundingBox {
+	south, west := offsetMeters(lat, lon, -meters, -meters)
+	north, east := offsetMeters(lat, lon, meters, meters)
+	return BoundingBox{South: south, West: west, North: north, East: east}
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180.0
+}
+
+// distanceMeters returns the great circle distance between two lat/lon points
+func distanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	rLat1, rLat2 := toRadians(lat1), toRadians(lat2)
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rLat1)*math.Cos(rLat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// bearingDegrees returns the initial bearing, in degrees true, of the great circle from
+// the first point to the second
+func bearingDegrees(lat1, lon1, lat2, lon2 float64) float64 {
+	rLat1, rLat2 := toRadians(lat1), toRadians(lat2)
+	dLon := toRadians(lon2 - lon1)
+
+	y := math.Sin(dLon) * math.Cos(rLat2)
+	x := math.Cos(rLat1)*math.Sin(rLat2) - math.Sin(rLat1)*math.Cos(rLat2)*math.Cos(dLon)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// angleBetween returns the smallest difference between two bearings, 0 to 180 degrees
+func angleBetween(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	if d > 180 {
+		return 360 - d
+	}
+	return d
+}
@@ -0,0 +1,227 @@
+package shipdata
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joemadeus/tugsy/tugsy/config"
+)
+
+const (
+	ZoneEnter ZoneEventType = iota
+	ZoneExit
+	ZoneDwell
+)
+
+type ZoneEventType int
+
+func (t ZoneEventType) String() string {
+	switch t {
+	case ZoneEnter:
+		return "entered"
+	case ZoneExit:
+		return "left"
+	case ZoneDwell:
+		return "is dwelling in"
+	default:
+		return "unknown"
+	}
+}
+
+type ZoneConfigError struct {
+	Zone   string
+	Reason string
+}
+
+func (e ZoneConfigError) Error() string {
+	return fmt.Sprintf("zone %q: %s", e.Zone, e.Reason)
+}
+
+// A Zone is a named polygon: a berth, a channel, an anchorage. Points are [lat, lon]
+// pairs, in order around the polygon; it's closed automatically
+type Zone struct {
+	Name   string
+	Points [][]float64
+
+	// a vessel that stays in the zone this long raises a ZoneDwell event, once per visit.
+	// Zero disables this
+	Dwell time.Duration
+
+	// if set, events in this zone are added to the alert log as well as being sent to
+	// subscribers
+	Alert bool
+
+	// if set, vessels stopping in this zone are arriving in port
+	Berth bool
+}
+
+// Contains returns true if lat/lon is inside the zone, by counting crossings of a ray
+// cast east from it
+func (z *Zone) Contains(lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(z.Points)-1; i < len(z.Points); j, i = i, i+1 {
+		latI, lonI := z.Points[i][0], z.Points[i][1]
+		latJ, lonJ := z.Points[j][0], z.Points[j][1]
+		if (latI > lat) != (latJ > lat) && lon < lonI+(lat-latI)/(latJ-latI)*(lonJ-lonI) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// A ZoneEvent records a vessel entering, leaving or dwelling in a zone. At is when the
+// position that triggered it was received
+type ZoneEvent struct {
+	Type     ZoneEventType
+	Zone     *Zone
+	MMSI     uint32
+	At       time.Time
+	Position Positionable
+}
+
+func (e ZoneEvent) String() string {
+	return fmt.Sprintf("MMSI %d %s %s", e.MMSI, e.Type, e.Zone.Name)
+}
+
+// a vessel's visit to a zone
+type zoneVisit struct {
+	entered time.Time
+	dwelled bool
+}
+
+// Geofences evaluates vessel positions against a set of zones, keeping track of which
+// vessels are in which zones and sending an event to every subscriber when that changes
+type Geofences struct {
+	sync.Mutex
+
+	zones       []*Zone
+	visits      map[uint32]map[*Zone]*zoneVisit
+	subscribers subscriberList
+}
+
+func NewGeofences(zones []*Zone) (*Geofences, error) {
+	names := make(map[string]struct{})
+	for _, zone := range zones {
+		if len(zone.Points) < 3 {
+			return nil, ZoneConfigError{zone.Name, "needs at least three points"}
+		}
+		for _, point := range zone.Points {
+			if len(point) != 2 {
+				return nil, ZoneConfigError{zone.Name, "points must be [lat, lon] pairs"}
+			}
+		}
+		if _, ok := names[zone.Name]; ok {
+			return nil, ZoneConfigError{zone.Name, "is defined more than once"}
+		}
+		names[zone.Name] = struct{}{}
+	}
+
+	return &Geofences{
+		zones:       zones,
+		visits:      make(map[uint32]map[*Zone]*zoneVisit),
+		subscribers: subscriberList{kind: "zone event"},
+	}, nil
+}
+
+// GeofencesFromConfig creates Geofences from the zones key. With no zones configured,
+// nothing is ever inside one
+func GeofencesFromConfig(cfg *config.Config) (*Geofences, error) {
+	var zones []*Zone
+	if cfg.IsSet("zones") {
+		if err := cfg.UnmarshalKey("zones", &zones); err != nil {
+			return nil, err
+		}
+	}
+	return NewGeofences(zones)
+}
+
+// Zones returns the zones, which must not be modified
+func (g *Geofences) Zones() []*Zone {
+	return g.zones
+}
+
+// Subscribe returns a channel that receives every zone event from now on, with room for
+// buffer of them
+func (g *Geofences) Subscribe(buffer int) <-chan ZoneEvent {
+	g.Lock()
+	defer g.Unlock()
+
+	events := make(chan ZoneEvent, buffer)
+	g.subscribers.add(events)
+	return events
+}
+
+// Unsubscribe stops sending events to a channel returned by Subscribe, and closes it
+func (g *Geofences) Unsubscribe(events <-chan ZoneEvent) {
+	g.Lock()
+	defer g.Unlock()
+	g.subscribers.remove(events)
+}
+
+// Inside returns the zones the vessel is in
+func (g *Geofences) Inside(mmsi uint32) []*Zone {
+	g.Lock()
+	defer g.Unlock()
+
+	var zones []*Zone
+	for _, zone := range g.zones {
+		if _, ok := g.visits[mmsi][zone]; ok {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
+
+// evaluate checks a vessel's new position against every zone, returning the events it
+// causes after sending them to the subscribers
+func (g *Geofences) evaluate(position Positionable) []ZoneEvent {
+	report := position.GetPositionReport()
+	at := position.ReceivedTime()
+
+	g.Lock()
+	defer g.Unlock()
+
+	visits := g.visits[report.MMSI]
+	var events []ZoneEvent
+	for _, zone := range g.zones {
+		visit, wasInside := visits[zone]
+		inside := zone.Contains(report.Lat, report.Lon)
+
+		switch {
+		case inside && wasInside == false:
+			if visits == nil {
+				visits = make(map[*Zone]*zoneVisit)
+				g.visits[report.MMSI] = visits
+			}
+			visits[zone] = &zoneVisit{entered: at}
+			events = append(events, ZoneEvent{ZoneEnter, zone, report.MMSI, at, position})
+
+		case inside == false && wasInside:
+			delete(visits, zone)
+			events = append(events, ZoneEvent{ZoneExit, zone, report.MMSI, at, position})
+
+		case inside && zone.Dwell > 0 && visit.dwelled == false && at.Sub(visit.entered) >= zone.Dwell:
+			visit.dwelled = true
+			events = append(events, ZoneEvent{ZoneDwell, zone, report.MMSI, at, position})
+		}
+	}
+
+	if visits != nil && len(visits) == 0 {
+		delete(g.visits, report.MMSI)
+	}
+
+	for _, event := range events {
+		g.subscribers.publish(event)
+	}
+
+	return events
+}
+
+// forget drops what's known about a vessel that's no longer being tracked, without
+// raising any events
+func (g *Geofences) forget(mmsi uint32) {
+	g.Lock()
+	defer g.Unlock()
+	delete(g.visits, mmsi)
+}
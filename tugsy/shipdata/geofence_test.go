@@ -0,0 +1,54 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneEvents(t *testing.T) {
+	berth := &Zone{
+		Name:   "berth",
+		Points: [][]float64{{41.80, -71.40}, {41.80, -71.39}, {41.79, -71.39}, {41.79, -71.40}},
+		Dwell:  10 * time.Minute,
+		Alert:  true,
+	}
+	geofences, err := NewGeofences([]*Zone{berth})
+	assert.NoError(t, err)
+
+	aisData := NewAISData()
+	aisData.Geofences = geofences
+	events := geofences.Subscribe(10)
+
+	now := time.Now()
+	aisData.AddPosition(positionAt(41.801, -71.395, now)) // outside
+	aisData.AddPosition(positionAt(41.7995, -71.395, now.Add(time.Minute)))
+	aisData.AddPosition(positionAt(41.7995, -71.395, now.Add(11*time.Minute)))
+	aisData.AddPosition(positionAt(41.801, -71.395, now.Add(12*time.Minute)))
+
+	for _, expected := range []ZoneEventType{ZoneEnter, ZoneDwell, ZoneExit} {
+		select {
+		case event := <-events:
+			assert.Equal(t, expected, event.Type)
+			assert.Equal(t, uint32(1), event.MMSI)
+			assert.Equal(t, berth, event.Zone)
+		default:
+			t.Fatalf("expected a %s event", expected)
+		}
+	}
+	assert.Equal(t, 0, len(events))
+	assert.Nil(t, geofences.Inside(1))
+
+	// entering and dwelling are added to the alert log, as is leaving
+	assert.Equal(t, 3, len(aisData.Alerts()))
+
+	geofences.Unsubscribe(events)
+	_, open := <-events
+	assert.False(t, open)
+}
+
+func TestBadZones(t *testing.T) {
+	_, err := NewGeofences([]*Zone{{Name: "line", Points: [][]float64{{41.8, -71.4}, {41.7, -71.4}}}})
+	assert.IsType(t, ZoneConfigError{}, err)
+}
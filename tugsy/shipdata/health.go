@@ -0,0 +1,178 @@
+package shipdata
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	backoffBase           = 1 * time.Second  // the delay after the first failure
+	backoffMax            = 2 * time.Minute  // the longest delay between attempts
+	backoffFailAttempts   = 12               // consecutive failures before a router is failed
+	stableConnectionDur   = 1 * time.Minute  // connections lasting this long reset the backoff
+	defaultReviveInterval = 10 * time.Minute // how long a failed router waits to be revived
+)
+
+type RouterState int
+
+const (
+	RouterIdle RouterState = iota
+	RouterConnecting
+	RouterConnected
+	RouterBackingOff
+	RouterFailed
+)
+
+func (s RouterState) String() string {
+	switch s {
+	case RouterIdle:
+		return "idle"
+	case RouterConnecting:
+		return "connecting"
+	case RouterConnected:
+		return "connected"
+	case RouterBackingOff:
+		return "backing off"
+	case RouterFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// RouterStatus is a snapshot of a RemoteAISServer's health
+type RouterStatus struct {
+	State         RouterState
+	Since         time.Time // when the router entered State
+	Attempts      int       // consecutive failed connection attempts
+	LastError     error
+	LastErrorTime time.Time
+	LastConnected time.Time
+	NextAttempt   time.Time // when backing off, the time of the next attempt
+}
+
+// routerHealth tracks a router's state transitions. It's safe to query from any goroutine
+type routerHealth struct {
+	sync.Mutex
+	status RouterStatus
+}
+
+func (h *routerHealth) get() RouterStatus {
+	h.Lock()
+	defer h.Unlock()
+	return h.status
+}
+
+func (h *routerHealth) enter(state RouterState) {
+	h.Lock()
+	defer h.Unlock()
+
+	now := time.Now()
+	if state != h.status.State {
+		h.status.State = state
+		h.status.Since = now
+	}
+
+	if state == RouterConnected {
+		h.status.LastConnected = now
+	}
+	if state != RouterBackingOff {
+		h.status.NextAttempt = time.Time{}
+	}
+}
+
+// failure records a failed attempt and returns the number of consecutive failures
+func (h *routerHealth) failure(err error) int {
+	h.Lock()
+	defer h.Unlock()
+
+	h.status.Attempts++
+	if err != nil {
+		h.status.LastError = err
+		h.status.LastErrorTime = time.Now()
+	}
+	return h.status.Attempts
+}
+
+func (h *routerHealth) backingOff(delay time.Duration) {
+	h.enter(RouterBackingOff)
+
+	h.Lock()
+	defer h.Unlock()
+	h.status.NextAttempt = time.Now().Add(delay)
+}
+
+func (h *routerHealth) resetAttempts() {
+	h.Lock()
+	defer h.Unlock()
+	h.status.Attempts = 0
+}
+
+// backoffDelay returns the delay before the next attempt after the given number of
+// consecutive failures: exponential, capped at backoffMax, with jitter so that several
+// routers failing together don't all retry in lockstep
+func backoffDelay(attempts int) time.Duration {
+	delay := backoffMax
+	if attempts < 32 {
+		if d := backoffBase << uint(attempts-1); d > 0 && d < backoffMax {
+			delay = d
+		}
+	}
+
+	// somewhere between half and all of the delay
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// A Supervisor runs a set of RemoteAISServers, reviving any that fail after
+// ReviveInterval. Routers fail only after repeated, backed-off attempts to connect, so
+// the revival schedule is much slower than their own
+type Supervisor struct {
+	Routers        []*RemoteAISServer
+	ReviveInterval time.Duration
+}
+
+func NewSupervisor(routers []*RemoteAISServer, reviveInterval time.Duration) *Supervisor {
+	if reviveInterval <= 0 {
+		reviveInterval = defaultReviveInterval
+	}
+
+	return &Supervisor{Routers: routers, ReviveInterval: reviveInterval}
+}
+
+// Run runs all the routers until ctx is cancelled, returning once they've all exited
+func (s *Supervisor) Run(ctx context.Context) {
+	var routers sync.WaitGroup
+	for _, router := range s.Routers {
+		routers.Add(1)
+		go func(router *RemoteAISServer) {
+			defer routers.Done()
+			s.supervise(ctx, router)
+		}(router)
+	}
+
+	routers.Wait()
+	logger.Info("router supervisor exiting")
+}
+
+func (s *Supervisor) supervise(ctx context.Context, router *RemoteAISServer) {
+	for {
+		router.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger.Warnf("AIS source %s has failed, reviving in %v", router.SourceName, s.ReviveInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.ReviveInterval):
+		}
+
+		logger.Infof("Reviving AIS source %s", router.SourceName)
+		router.health.resetAttempts()
+	}
+}
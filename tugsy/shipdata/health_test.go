@@ -0,0 +1,59 @@
+package shipdata
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	for attempts := 1; attempts < 40; attempts++ {
+		d := backoffDelay(attempts)
+		assert.True(t, d >= backoffBase/2, "attempt %d delay %v too short", attempts, d)
+		assert.True(t, d <= backoffMax, "attempt %d delay %v too long", attempts, d)
+	}
+
+	assert.True(t, backoffDelay(1) <= backoffBase)
+	assert.True(t, backoffDelay(20) >= backoffMax/2)
+}
+
+type failingSource struct {
+	err error
+}
+
+func (s *failingSource) Run(ctx context.Context, sentences chan<- string, connected func()) error {
+	return s.err
+}
+
+func TestFailedConnectionBacksOff(t *testing.T) {
+	refused := errors.New("connection refused")
+	router := &RemoteAISServer{
+		SourceName: "test",
+		aisData:    NewAISData(),
+		source:     &failingSource{refused},
+		stats:      SourceStats{ByType: make(map[uint8]uint64)},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		router.Run(ctx)
+		close(done)
+	}()
+
+	for router.Status().State != RouterBackingOff {
+		time.Sleep(time.Millisecond)
+	}
+
+	status := router.Status()
+	assert.Equal(t, 1, status.Attempts)
+	assert.Equal(t, refused, status.LastError)
+	assert.False(t, status.NextAttempt.IsZero())
+
+	cancel()
+	<-done
+	assert.Equal(t, RouterIdle, router.Status().State)
+}
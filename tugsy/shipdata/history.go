@@ -0,0 +1,354 @@
+package shipdata
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/andmarios/aislib"
+	_ "github.com/mattn/go-sqlite3"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	historyQueueSize     = 4096
+	historyBatchSize     = 500
+	historyFlushInterval = 2 * time.Second
+)
+
+var historySchema = []string{
+	`CREATE TABLE IF NOT EXISTS positions (
+		mmsi     INTEGER NOT NULL,
+		received INTEGER NOT NULL, -- unix milliseconds
+		source   TEXT NOT NULL,
+		station  TEXT NOT NULL,
+		type     INTEGER NOT NULL,
+		lat      REAL NOT NULL,
+		lon      REAL NOT NULL,
+		speed    REAL NOT NULL,
+		course   REAL NOT NULL,
+		heading  INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS positions_mmsi_received ON positions (mmsi, received)`,
+	`CREATE INDEX IF NOT EXISTS positions_received ON positions (received)`,
+	// an R*Tree over the positions, keyed by the positions' rowids, for area queries. A
+	// position is a box with no size. Databases from before it existed are indexed the
+	// first time they're opened
+	`DROP INDEX IF EXISTS positions_lat_lon`,
+	`CREATE VIRTUAL TABLE IF NOT EXISTS positions_area USING rtree (id, min_lat, max_lat, min_lon, max_lon)`,
+	`INSERT INTO positions_area (id, min_lat, max_lat, min_lon, max_lon)
+		SELECT rowid, lat, lat, lon, lon FROM positions
+		WHERE NOT EXISTS (SELECT 1 FROM positions_area)`,
+	`CREATE TABLE IF NOT EXISTS voyage_data (
+		mmsi         INTEGER NOT NULL,
+		received     INTEGER NOT NULL,
+		source       TEXT NOT NULL,
+		station      TEXT NOT NULL,
+		imo          INTEGER NOT NULL,
+		callsign     TEXT NOT NULL,
+		vessel_name  TEXT NOT NULL,
+		ship_type    INTEGER NOT NULL,
+		to_bow       INTEGER NOT NULL,
+		to_stern     INTEGER NOT NULL,
+		to_port      INTEGER NOT NULL,
+		to_starboard INTEGER NOT NULL,
+		draught      REAL NOT NULL,
+		destination  TEXT NOT NULL,
+		eta          INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS voyage_data_mmsi_received ON voyage_data (mmsi, received)`,
+}
+
+// HistoryPosition is a position report as stored in the history database
+type HistoryPosition struct {
+	MMSI     uint32
+	Received time.Time
+	Source   string
+	Station  string
+	Type     uint8 // the AIS message type
+	Lat      float64
+	Lon      float64
+	Speed    float32
+	Course   float32
+	Heading  uint16
+}
+
+// HistoryVoyageData is a vessel's voyage data, as it stood after an update
+type HistoryVoyageData struct {
+	aislib.StaticVoyageData
+	Received time.Time
+	Source   string
+	Station  string
+}
+
+// A HistoryStore writes every position and voyage data update to a SQLite database and
+// answers queries over it. Writes are queued and committed in batches by Run, so
+// recording never blocks the decode loops: if the queue fills, updates are dropped and
+// counted
+type HistoryStore struct {
+	db      *sql.DB
+	queue   chan interface{} // *HistoryPosition or *HistoryVoyageData
+	dropped uint64           // accessed atomically
+}
+
+func OpenHistoryStore(path string) (*HistoryStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite allows only one writer, and queries are rare
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range historySchema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return &HistoryStore{db: db, queue: make(chan interface{}, historyQueueSize)}, nil
+}
+
+func (store *HistoryStore) Close() error {
+	return store.db.Close()
+}
+
+// RecordPosition queues a position to be written
+func (store *HistoryStore) RecordPosition(position Positionable) {
+	report := position.GetPositionReport()
+	store.enqueue(&HistoryPosition{
+		MMSI:     report.MMSI,
+		Received: position.ReceivedTime(),
+		Source:   position.Source(),
+		Station:  stationOf(position),
+		Type:     report.Type,
+		Lat:      report.Lat,
+		Lon:      report.Lon,
+		Speed:    report.Speed,
+		Course:   report.Course,
+		Heading:  report.Heading,
+	})
+}
+
+// RecordVoyageData queues voyage data to be written
+func (store *HistoryStore) RecordVoyageData(data *SourcedStaticVoyageData) {
+	store.enqueue(&HistoryVoyageData{
+		StaticVoyageData: data.StaticVoyageData,
+		Received:         data.ReceivedTime(),
+		Source:           data.Source(),
+		Station:          data.Station(),
+	})
+}
+
+func (store *HistoryStore) enqueue(row interface{}) {
+	select {
+	case store.queue <- row:
+	default:
+		if atomic.AddUint64(&store.dropped, 1) == 1 {
+			logger.Warn("history queue is full, dropping updates")
+		}
+	}
+}
+
+// Dropped returns the number of updates dropped because the queue was full
+func (store *HistoryStore) Dropped() uint64 {
+	return atomic.LoadUint64(&store.dropped)
+}
+
+// Run writes queued updates until ctx is cancelled, then writes whatever's left
+func (store *HistoryStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(historyFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]interface{}, 0, historyBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := store.write(batch); err != nil {
+			logger.WithError(err).Errorf("writing %d updates to the history store", len(batch))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case row := <-store.queue:
+					batch = append(batch, row)
+				default:
+					flush()
+					logger.Info("history store loop exiting")
+					return
+				}
+			}
+
+		case row := <-store.queue:
+			batch = append(batch, row)
+			if len(batch) >= historyBatchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (store *HistoryStore) write(batch []interface{}) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	positions, err := tx.Prepare(`INSERT INTO positions
+		(mmsi, received, source, station, type, lat, lon, speed, course, heading)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer positions.Close()
+
+	area, err := tx.Prepare(`INSERT INTO positions_area (id, min_lat, max_lat, min_lon, max_lon)
+		VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer area.Close()
+
+	voyageData, err := tx.Prepare(`INSERT INTO voyage_data
+		(mmsi, received, source, station, imo, callsign, vessel_name, ship_type, to_bow,
+		 to_stern, to_port, to_starboard, draught, destination, eta)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer voyageData.Close()
+
+	for _, row := range batch {
+		switch r := row.(type) {
+		case *HistoryPosition:
+			var result sql.Result
+			var id int64
+			result, err = positions.Exec(r.MMSI, toMillis(r.Received), r.Source, r.Station, r.Type,
+				r.Lat, r.Lon, r.Speed, r.Course, r.Heading)
+			if err == nil {
+				id, err = result.LastInsertId()
+			}
+			if err == nil {
+				_, err = area.Exec(id, r.Lat, r.Lat, r.Lon, r.Lon)
+			}
+		case *HistoryVoyageData:
+			_, err = voyageData.Exec(r.MMSI, toMillis(r.Received), r.Source, r.Station, r.IMO,
+				r.Callsign, r.VesselName, r.ShipType, r.ToBow, r.ToStern, r.ToPort, r.ToStarboard,
+				r.Draught, r.Destination, toMillis(r.ETA))
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Track returns the vessel's positions received between from and to, oldest first
+func (store *HistoryStore) Track(mmsi uint32, from, to time.Time) ([]HistoryPosition, error) {
+	return store.queryPositions(`SELECT mmsi, received, source, station, type, lat, lon, speed, course, heading
+		FROM positions WHERE mmsi = ? AND received BETWEEN ? AND ? ORDER BY received`,
+		mmsi, toMillis(from), toMillis(to))
+}
+
+// InArea returns every position inside the box received between from and to, ordered
+// by vessel and then oldest first. The R*Tree finds the candidates; since it stores its
+// coordinates with less precision, they're checked against the positions' own
+func (store *HistoryStore) InArea(box BoundingBox, from, to time.Time) ([]HistoryPosition, error) {
+	return store.queryPositions(`SELECT p.mmsi, p.received, p.source, p.station, p.type, p.lat, p.lon,
+		p.speed, p.course, p.heading
+		FROM positions_area a JOIN positions p ON p.rowid = a.id
+		WHERE a.max_lat >= ? AND a.min_lat <= ? AND a.max_lon >= ? AND a.min_lon <= ?
+		AND p.lat BETWEEN ? AND ? AND p.lon BETWEEN ? AND ? AND p.received BETWEEN ? AND ?
+		ORDER BY p.mmsi, p.received`,
+		box.South, box.North, box.West, box.East,
+		box.South, box.North, box.West, box.East, toMillis(from), toMillis(to))
+}
+
+// VoyageDataHistory returns every update to the vessel's voyage data received between
+// from and to, oldest first
+func (store *HistoryStore) VoyageDataHistory(mmsi uint32, from, to time.Time) ([]HistoryVoyageData, error) {
+	rows, err := store.db.Query(`SELECT mmsi, received, source, station, imo, callsign, vessel_name,
+		ship_type, to_bow, to_stern, to_port, to_starboard, draught, destination, eta
+		FROM voyage_data WHERE mmsi = ? AND received BETWEEN ? AND ? ORDER BY received`,
+		mmsi, toMillis(from), toMillis(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var updates []HistoryVoyageData
+	for rows.Next() {
+		var u HistoryVoyageData
+		var received, eta int64
+		if err := rows.Scan(&u.MMSI, &received, &u.Source, &u.Station, &u.IMO, &u.Callsign,
+			&u.VesselName, &u.ShipType, &u.ToBow, &u.ToStern, &u.ToPort, &u.ToStarboard,
+			&u.Draught, &u.Destination, &eta); err != nil {
+			return nil, err
+		}
+		u.Received, u.ETA = fromMillis(received), fromMillis(eta)
+		updates = append(updates, u)
+	}
+
+	return updates, rows.Err()
+}
+
+func (store *HistoryStore) queryPositions(query string, args ...interface{}) ([]HistoryPosition, error) {
+	rows, err := store.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions []HistoryPosition
+	for rows.Next() {
+		var p HistoryPosition
+		var received int64
+		if err := rows.Scan(&p.MMSI, &received, &p.Source, &p.Station, &p.Type, &p.Lat, &p.Lon,
+			&p.Speed, &p.Course, &p.Heading); err != nil {
+			return nil, err
+		}
+		p.Received = fromMillis(received)
+		positions = append(positions, p)
+	}
+
+	return positions, rows.Err()
+}
+
+// stationOf returns the tag block station of any of the Sourced* position types
+func stationOf(position Positionable) string {
+	if s, ok := position.(interface{ Station() string }); ok {
+		return s.Station()
+	}
+	return ""
+}
+
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
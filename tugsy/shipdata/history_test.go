@@ -0,0 +1,81 @@
+package shipdata
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andmarios/aislib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tugsy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := OpenHistoryStore(filepath.Join(dir, "history.db"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	aisData := NewAISData()
+	aisData.History = store
+
+	start := time.Unix(1510000000, 0)
+	for i := 0; i < 3; i++ {
+		st := SourceAndTime{sourceName: "radio", station: "r003669945", receivedTime: start.Add(time.Duration(i) * time.Minute)}
+		aisData.AddPosition(&SourcedClassAPositionReport{aislib.ClassAPositionReport{PositionReport: aislib.PositionReport{Type: 1, MMSI: 367000001, Lat: 41.80 + float64(i)/100, Lon: -71.39, Speed: 36}}, st})
+	}
+	// quarantined by the outlier filter, so it isn't recorded
+	jump := SourceAndTime{sourceName: "radio", receivedTime: start.Add(3 * time.Minute)}
+	aisData.AddPosition(&SourcedClassAPositionReport{aislib.ClassAPositionReport{PositionReport: aislib.PositionReport{Type: 1, MMSI: 367000001, Lat: 40.5, Lon: -71.39, Speed: 36}}, jump})
+	outside := SourceAndTime{sourceName: "radio", receivedTime: start}
+	aisData.AddPosition(&SourcedClassBPositionReport{aislib.ClassBPositionReport{PositionReport: aislib.PositionReport{Type: 18, MMSI: 367000002, Lat: 40.5, Lon: -71.39}}, outside})
+
+	part := SourceAndTime{sourceName: "radio", receivedTime: start}
+	aisData.UpdateStaticDataReport(&SourcedStaticDataReport{StaticDataReport{MMSI: 367000002, PartNumber: 0, VesselName: "SEA DOG"}, part})
+	// a repeat changes nothing, so it isn't recorded again
+	aisData.UpdateStaticDataReport(&SourcedStaticDataReport{StaticDataReport{MMSI: 367000002, PartNumber: 0, VesselName: "SEA DOG"}, part})
+
+	// Run writes what's queued once cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store.Run(ctx)
+	assert.Equal(t, uint64(0), store.Dropped())
+
+	track, err := store.Track(367000001, start, start.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(track))
+	assert.InDelta(t, 41.81, track[1].Lat, 0.000001)
+	assert.Equal(t, start.Add(time.Minute), track[1].Received)
+	assert.Equal(t, "r003669945", track[1].Station)
+	assert.Equal(t, uint8(1), track[1].Type)
+
+	track, err = store.Track(367000001, start, start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(track))
+
+	inArea, err := store.InArea(BoundingBox{South: 40, West: -72, North: 41, East: -71}, start, start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(inArea))
+	assert.Equal(t, uint32(367000002), inArea[0].MMSI)
+
+	updates, err := store.VoyageDataHistory(367000002, start, start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(updates))
+	assert.Equal(t, "SEA DOG", updates[0].VesselName)
+}
+
+func TestHistoryStoreCountsDroppedUpdates(t *testing.T) {
+	store := &HistoryStore{queue: make(chan interface{}, 1)}
+	data := &SourcedStaticVoyageData{aislib.StaticVoyageData{MMSI: 367000002}, SourceAndTime{sourceName: "radio", receivedTime: time.Now()}}
+
+	store.RecordVoyageData(data)
+	assert.Equal(t, uint64(0), store.Dropped())
+	store.RecordVoyageData(data)
+	store.RecordVoyageData(data)
+	assert.Equal(t, uint64(2), store.Dropped())
+}
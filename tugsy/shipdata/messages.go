@@ -0,0 +1,256 @@
+package shipdata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andmarios/aislib"
+)
+
+// aislib only decodes message types 1-5, 8 and 18. The rest are decoded here, following
+// the field layouts in ITU-R M.1371 (see also https://gpsd.gitlab.io/gpsd/AIVDM.html)
+
+type PayloadTooShortError struct {
+	Type       uint8
+	Bits, Want int
+}
+
+func (e PayloadTooShortError) Error() string {
+	return fmt.Sprintf("message type %d payload has %d bits, wanted %d", e.Type, e.Bits, e.Want)
+}
+
+// payloadBits is an unarmored AIS payload, one bit per byte
+type payloadBits []byte
+
+func unarmor(payload string) payloadBits {
+	bits := make(payloadBits, 0, len(payload)*6)
+	for i := 0; i < len(payload); i++ {
+		c := payload[i] - 48
+		if c > 40 {
+			c -= 8
+		}
+		for b := 5; b >= 0; b-- {
+			bits = append(bits, (c>>uint(b))&1)
+		}
+	}
+	return bits
+}
+
+// checkLength returns an error if there are fewer than want bits
+func (p payloadBits) checkLength(messageType uint8, want int) error {
+	if len(p) < want {
+		return PayloadTooShortError{messageType, len(p), want}
+	}
+	return nil
+}
+
+// uint returns the unsigned value of the length bits starting at start
+func (p payloadBits) uint(start, length int) uint32 {
+	var v uint32
+	for _, b := range p[start : start+length] {
+		v = v<<1 | uint32(b)
+	}
+	return v
+}
+
+// int returns the two's complement value of the length bits starting at start
+func (p payloadBits) int(start, length int) int32 {
+	v := p.uint(start, length)
+	if p[start] == 1 {
+		return int32(v) - int32(1<<uint(length))
+	}
+	return int32(v)
+}
+
+func (p payloadBits) bool(start int) bool {
+	return p[start] == 1
+}
+
+// text decodes length bits of six-bit ASCII, dropping the '@' padding and trailing spaces
+func (p payloadBits) text(start, length int) string {
+	var sb strings.Builder
+	for i := start; i+6 <= start+length; i += 6 {
+		c := byte(p.uint(i, 6))
+		if c < 32 {
+			c += 64
+		}
+		if c == '@' {
+			break
+		}
+		sb.WriteByte(c)
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+// ExtendedClassBPositionReport is message type 19, sent by some Class B transponders in
+// place of a type 18 & 24 pair. It carries both position and static data
+type ExtendedClassBPositionReport struct {
+	aislib.PositionReport
+	VesselName  string
+	ShipType    uint8
+	ToBow       uint16
+	ToStern     uint16
+	ToPort      uint8
+	ToStarboard uint8
+	EPFD        uint8
+	DTE         bool
+	Assigned    bool
+}
+
+func DecodeExtendedClassBPositionReport(payload string) (ExtendedClassBPositionReport, error) {
+	p := unarmor(payload)
+	if err := p.checkLength(19, 312); err != nil {
+		return ExtendedClassBPositionReport{}, err
+	}
+
+	return ExtendedClassBPositionReport{
+		PositionReport: aislib.PositionReport{
+			Type:     uint8(p.uint(0, 6)),
+			Repeat:   uint8(p.uint(6, 2)),
+			MMSI:     p.uint(8, 30),
+			Speed:    float32(p.uint(46, 10)) / 10,
+			Accuracy: p.bool(56),
+			Lon:      float64(p.int(57, 28)) / 600000,
+			Lat:      float64(p.int(85, 27)) / 600000,
+			Course:   float32(p.uint(112, 12)) / 10,
+			Heading:  uint16(p.uint(124, 9)),
+			Second:   uint8(p.uint(133, 6)),
+			RAIM:     p.bool(305),
+		},
+		VesselName:  p.text(143, 120),
+		ShipType:    uint8(p.uint(263, 8)),
+		ToBow:       uint16(p.uint(271, 9)),
+		ToStern:     uint16(p.uint(280, 9)),
+		ToPort:      uint8(p.uint(289, 6)),
+		ToStarboard: uint8(p.uint(295, 6)),
+		EPFD:        uint8(p.uint(301, 4)),
+		DTE:         p.bool(306),
+		Assigned:    p.bool(307),
+	}, nil
+}
+
+// StaticDataReport is message type 24, the Class B equivalent of type 5. It arrives in
+// two parts: part A (PartNumber 0) carries only the vessel name, part B (PartNumber 1)
+// the ship type, call sign and dimensions
+type StaticDataReport struct {
+	Repeat         uint8
+	MMSI           uint32
+	PartNumber     uint8
+	VesselName     string
+	ShipType       uint8
+	VendorID       string
+	Callsign       string
+	ToBow          uint16
+	ToStern        uint16
+	ToPort         uint8
+	ToStarboard    uint8
+	MothershipMMSI uint32 // for auxiliary craft (MMSI 98MIDxxxx), in place of dimensions
+}
+
+func DecodeStaticDataReport(payload string) (StaticDataReport, error) {
+	p := unarmor(payload)
+	if err := p.checkLength(24, 160); err != nil {
+		return StaticDataReport{}, err
+	}
+
+	report := StaticDataReport{
+		Repeat:     uint8(p.uint(6, 2)),
+		MMSI:       p.uint(8, 30),
+		PartNumber: uint8(p.uint(38, 2)),
+	}
+
+	if report.PartNumber == 0 {
+		report.VesselName = p.text(40, 120)
+		return report, nil
+	}
+
+	if err := p.checkLength(24, 162); err != nil {
+		return StaticDataReport{}, err
+	}
+
+	report.ShipType = uint8(p.uint(40, 8))
+	report.VendorID = p.text(48, 42)
+	report.Callsign = p.text(90, 42)
+	if isAuxiliaryCraft(report.MMSI) {
+		report.MothershipMMSI = p.uint(132, 30)
+	} else {
+		report.ToBow = uint16(p.uint(132, 9))
+		report.ToStern = uint16(p.uint(141, 9))
+		report.ToPort = uint8(p.uint(150, 6))
+		report.ToStarboard = uint8(p.uint(156, 6))
+	}
+
+	return report, nil
+}
+
+// isAuxiliaryCraft returns true for MMSIs of the form 98MIDxxxx, i.e. craft associated
+// with a parent ship
+func isAuxiliaryCraft(mmsi uint32) bool {
+	return mmsi/10000000 == 98
+}
+
+// LongRangePositionReport is message type 27, a coarse position (to a tenth of a minute)
+// intended for reception by satellite
+type LongRangePositionReport struct {
+	aislib.PositionReport
+	Status uint8
+	GNSS   bool // true if the position is current GNSS output, false if it's stale
+}
+
+func DecodeLongRangePositionReport(payload string) (LongRangePositionReport, error) {
+	p := unarmor(payload)
+	if err := p.checkLength(27, 96); err != nil {
+		return LongRangePositionReport{}, err
+	}
+
+	report := LongRangePositionReport{
+		PositionReport: aislib.PositionReport{
+			Type:     uint8(p.uint(0, 6)),
+			Repeat:   uint8(p.uint(6, 2)),
+			MMSI:     p.uint(8, 30),
+			Accuracy: p.bool(38),
+			RAIM:     p.bool(39),
+			Lon:      float64(p.int(44, 18)) / 600,
+			Lat:      float64(p.int(62, 17)) / 600,
+			Speed:    float32(p.uint(79, 6)),
+			Course:   float32(p.uint(85, 9)),
+			Heading:  511, // not available
+			Second:   60,  // not available
+		},
+		Status: uint8(p.uint(40, 4)),
+		GNSS:   p.bool(94) == false, // the bit is set when the position is NOT current
+	}
+
+	// map the "not available" values onto those used by the other position reports
+	if report.Speed == 63 {
+		report.Speed = 102.3
+	}
+	if report.Course == 511 {
+		report.Course = 360
+	}
+
+	return report, nil
+}
+
+type SourcedExtendedClassBPositionReport struct {
+	ExtendedClassBPositionReport
+	SourceAndTime
+}
+
+func (ebPos *SourcedExtendedClassBPositionReport) GetPositionReport() *aislib.PositionReport {
+	return &ebPos.PositionReport
+}
+
+type SourcedLongRangePositionReport struct {
+	LongRangePositionReport
+	SourceAndTime
+}
+
+func (lrPos *SourcedLongRangePositionReport) GetPositionReport() *aislib.PositionReport {
+	return &lrPos.PositionReport
+}
+
+type SourcedStaticDataReport struct {
+	StaticDataReport
+	SourceAndTime
+}
@@ -0,0 +1,216 @@
+package shipdata
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/joemadeus/tugsy/tugsy/weatherdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeStaticDataReport(t *testing.T) {
+	partA, err := DecodeStaticDataReport("H42O55i18tMET00000000000000")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(271041815), partA.MMSI)
+	assert.Equal(t, uint8(0), partA.PartNumber)
+	assert.Equal(t, "PROGUY", partA.VesselName)
+
+	partB, err := DecodeStaticDataReport("H42O55lti4hhhilD3nink000?050")
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(1), partB.PartNumber)
+	assert.Equal(t, uint8(60), partB.ShipType)
+	assert.Equal(t, "TC6163", partB.Callsign)
+	assert.Equal(t, uint16(15), partB.ToStern)
+	assert.Equal(t, uint8(5), partB.ToStarboard)
+
+	_, err = DecodeStaticDataReport("H42O55")
+	assert.Error(t, err)
+}
+
+func TestDecodeExtendedClassBPositionReport(t *testing.T) {
+	report, err := DecodeExtendedClassBPositionReport("C5N3SRgPEnJGEBT>NhWAwwo862PaLELTBJ:V00000000S0D:R220")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(367059850), report.MMSI)
+	assert.InDelta(t, 8.7, report.Speed, 0.001)
+	assert.InDelta(t, -88.810392, report.Lon, 0.000001)
+	assert.InDelta(t, 29.543695, report.Lat, 0.000001)
+	assert.InDelta(t, 335.9, report.Course, 0.001)
+	assert.Equal(t, uint8(46), report.Second)
+	assert.Equal(t, "CAPT.J.RIMES", report.VesselName)
+	assert.Equal(t, uint8(70), report.ShipType)
+	assert.Equal(t, uint16(21), report.ToStern)
+}
+
+func TestDecodeLongRangePositionReport(t *testing.T) {
+	report, err := DecodeLongRangePositionReport("KC5E2b@U19PFdLbL")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(206914217), report.MMSI)
+	assert.InDelta(t, 137.023333, report.Lon, 0.000001)
+	assert.InDelta(t, 4.84, report.Lat, 0.000001)
+	assert.InDelta(t, 57, report.Speed, 0.001)
+	assert.InDelta(t, 167, report.Course, 0.001)
+	assert.Equal(t, uint8(2), report.Status)
+	assert.True(t, report.GNSS)
+}
+
+func TestStaticDataReportPartsMerge(t *testing.T) {
+	aisData := NewAISData()
+	partA, _ := DecodeStaticDataReport("H42O55i18tMET00000000000000")
+	partB, _ := DecodeStaticDataReport("H42O55lti4hhhilD3nink000?050")
+
+	aisData.UpdateStaticDataReport(&SourcedStaticDataReport{partA, SourceAndTime{sourceName: "test", receivedTime: time.Now()}})
+	history, ok := aisData.ShipHistory(271041815)
+	assert.True(t, ok)
+	first := history.VoyageData()
+	assert.Equal(t, "PROGUY", first.VesselName)
+	assert.Equal(t, uint8(0), first.ShipType)
+
+	aisData.UpdateStaticDataReport(&SourcedStaticDataReport{partB, SourceAndTime{sourceName: "test", receivedTime: time.Now()}})
+	merged := history.VoyageData()
+	assert.Equal(t, "PROGUY", merged.VesselName)
+	assert.Equal(t, uint8(60), merged.ShipType)
+	assert.Equal(t, "TC6163", merged.Callsign)
+
+	// the earlier copy is left alone
+	assert.Equal(t, uint8(0), first.ShipType)
+}
+
+func TestDecodeAidToNavigationReport(t *testing.T) {
+	// the safe water buoy at the entrance to Narragansett Bay
+	report, err := DecodeAidToNavigationReport("ENk`snI70a90h10dh2W:@71@@@@=LVUF;mh6000003vP000")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(993672153), report.MMSI)
+	assert.Equal(t, "NARRA BAY ENT NB", report.Name)
+	assert.Equal(t, "Beacon, safe water", report.AidTypeName())
+	assert.InDelta(t, -71.389297, report.Lon, 0.000001)
+	assert.InDelta(t, 41.383333, report.Lat, 0.000001)
+	assert.False(t, report.OffPosition)
+}
+
+// armor encodes bits as an AIS payload, padding the last character with zeroes
+func armor(bits payloadBits) string {
+	payload := make([]byte, 0, len(bits)/6+1)
+	for i := 0; i < len(bits); i += 6 {
+		var c byte
+		for b := i; b < i+6; b++ {
+			c <<= 1
+			if b < len(bits) {
+				c |= bits[b]
+			}
+		}
+		if c > 39 {
+			c += 8
+		}
+		payload = append(payload, c+48)
+	}
+	return string(payload)
+}
+
+func (p payloadBits) set(start, length int, v int32) {
+	for i := 0; i < length; i++ {
+		p[start+length-1-i] = byte(v>>uint(i)) & 1
+	}
+}
+
+func TestDecodeMetHydroReport(t *testing.T) {
+	bits := make(payloadBits, 360)
+	bits.set(0, 6, 8)
+	bits.set(8, 30, 3669713)
+	bits.set(40, 10, 1)
+	bits.set(50, 6, 31)
+	bits.set(56, 25, -71400*60)
+	bits.set(81, 24, 41500*60)
+	bits.set(106, 5, 30)
+	bits.set(111, 5, 14)
+	bits.set(116, 6, 30)
+	bits.set(122, 7, 12)
+	bits.set(129, 7, 18)
+	bits.set(136, 9, 225)
+	bits.set(145, 9, 230)
+	bits.set(154, 11, -55)
+	bits.set(165, 7, 101)
+	bits.set(172, 10, 501)
+	bits.set(182, 9, 214)
+	bits.set(194, 7, 127)
+	bits.set(201, 12, 1125)
+	bits.set(215, 8, 12)
+	bits.set(223, 9, 45)
+	bits.set(276, 8, 255)
+	bits.set(326, 10, 501)
+	payload := armor(bits)
+
+	assert.True(t, isMetHydro(payload))
+	report, err := DecodeMetHydroReport(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3669713), report.MMSI)
+	assert.InDelta(t, -71.4, report.Lon, 0.000001)
+	assert.InDelta(t, 41.5, report.Lat, 0.000001)
+	assert.InDelta(t, 12, report.WindSpeedKts, 0.001)
+	assert.InDelta(t, 18, report.WindGustKts, 0.001)
+	assert.InDelta(t, 225, report.WindDirection, 0.001)
+	assert.InDelta(t, -5.5, report.AirTempC, 0.001)
+	assert.InDelta(t, 1013, report.PressureHPa, 0.001)
+	assert.InDelta(t, 1.25, report.WaterLevelM, 0.001)
+	assert.InDelta(t, 1.2, report.CurrentSpeedKts, 0.001)
+	assert.InDelta(t, 45, report.CurrentDirection, 0.001)
+
+	// not available
+	assert.True(t, math.IsNaN(report.HumidityPct))
+	assert.True(t, math.IsNaN(report.VisibilityNM))
+	assert.True(t, math.IsNaN(report.WaveHeightM))
+	assert.True(t, math.IsNaN(report.WaterTempC))
+
+	// observed on the 30th, received early on the 1st of the next month
+	received := time.Date(2018, time.May, 1, 0, 5, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2018, time.April, 30, 14, 30, 0, 0, time.UTC), report.observedAt(received))
+
+	// other binary broadcasts aren't met-hydro data
+	bits.set(50, 6, 11)
+	assert.False(t, isMetHydro(armor(bits)))
+	_, err = DecodeMetHydroReport(armor(bits))
+	assert.Error(t, err)
+}
+
+func TestMetHydroSpeedAndHeightNotAvailable(t *testing.T) {
+	bits := make(payloadBits, 360)
+	bits.set(0, 6, 8)
+	bits.set(8, 30, 3669713)
+	bits.set(40, 10, 1)
+	bits.set(50, 6, 31)
+	bits.set(215, 8, 250)
+	bits.set(276, 8, 250)
+
+	// 25.0 is the most either field can hold
+	report, err := DecodeMetHydroReport(armor(bits))
+	assert.NoError(t, err)
+	assert.InDelta(t, 25, report.CurrentSpeedKts, 0.001)
+	assert.InDelta(t, 25, report.WaveHeightM, 0.001)
+
+	// 251 is "not available", and 252 to 255 are reserved
+	for _, raw := range []int32{251, 252, 255} {
+		bits.set(215, 8, raw)
+		bits.set(276, 8, raw)
+		report, err = DecodeMetHydroReport(armor(bits))
+		assert.NoError(t, err)
+		assert.True(t, math.IsNaN(report.CurrentSpeedKts), "current speed %d", raw)
+		assert.True(t, math.IsNaN(report.WaveHeightM), "wave height %d", raw)
+	}
+}
+
+func TestMetHydroPublishedToWeather(t *testing.T) {
+	aisData := NewAISData()
+	aisData.Weather = weatherdata.NewWeatherData()
+
+	report := &SourcedMetHydroReport{
+		MetHydroReport: MetHydroReport{MMSI: 3669713, WindSpeedKts: 12, AirTempC: math.NaN()},
+		SourceAndTime:  SourceAndTime{sourceName: "test", receivedTime: time.Now()},
+	}
+	aisData.UpdateMetHydroReport(report)
+
+	station, ok := aisData.Weather.Station("AIS 003669713")
+	assert.True(t, ok)
+	assert.Equal(t, "test", station.Source)
+	assert.InDelta(t, 12, station.WindSpeedKts, 0.001)
+	assert.False(t, weatherdata.Available(station.AirTempC))
+}
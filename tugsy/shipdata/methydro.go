@@ -0,0 +1,149 @@
+package shipdata
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/joemadeus/tugsy/tugsy/weatherdata"
+)
+
+const (
+	metHydroDAC = 1
+	metHydroFI  = 31
+)
+
+// MetHydroReport is the IMO 289 meteorological and hydrographic data binary broadcast
+// (type 8, DAC 1, FI 31), sent by shore stations and instrumented buoys. Readings the
+// station didn't report are NaN. See IMO SN.1/Circ.289
+type MetHydroReport struct {
+	Repeat   uint8
+	MMSI     uint32
+	Lon      float64
+	Lat      float64
+	Accuracy bool
+
+	// the UTC day of the month, hour and minute of the observation. 0, 24 and 60 are
+	// "not available"
+	Day, Hour, Minute uint8
+
+	WindSpeedKts      float64
+	WindGustKts       float64
+	WindDirection     float64
+	WindGustDirection float64
+	AirTempC          float64
+	HumidityPct       float64
+	DewPointC         float64
+	PressureHPa       float64
+	VisibilityNM      float64
+	WaterLevelM       float64
+	CurrentSpeedKts   float64
+	CurrentDirection  float64
+	WaveHeightM       float64
+	WaterTempC        float64
+}
+
+// isMetHydro returns true if the binary broadcast's payload is IMO 289 met-hydro data
+func isMetHydro(payload string) bool {
+	p := unarmor(payload)
+	return len(p) >= 56 && p.uint(40, 10) == metHydroDAC && p.uint(50, 6) == metHydroFI
+}
+
+func DecodeMetHydroReport(payload string) (MetHydroReport, error) {
+	p := unarmor(payload)
+	if err := p.checkLength(8, 350); err != nil {
+		return MetHydroReport{}, err
+	}
+	if dac, fi := p.uint(40, 10), p.uint(50, 6); dac != metHydroDAC || fi != metHydroFI {
+		return MetHydroReport{}, fmt.Errorf("binary broadcast is DAC %d FI %d, not met-hydro data", dac, fi)
+	}
+
+	return MetHydroReport{
+		Repeat:   uint8(p.uint(6, 2)),
+		MMSI:     p.uint(8, 30),
+		Lon:      float64(p.int(56, 25)) / 60000,
+		Lat:      float64(p.int(81, 24)) / 60000,
+		Accuracy: p.bool(105),
+		Day:      uint8(p.uint(106, 5)),
+		Hour:     uint8(p.uint(111, 5)),
+		Minute:   uint8(p.uint(116, 6)),
+
+		WindSpeedKts:      reading(float64(p.uint(122, 7)), 127, 1, 0),
+		WindGustKts:       reading(float64(p.uint(129, 7)), 127, 1, 0),
+		WindDirection:     reading(float64(p.uint(136, 9)), 360, 1, 0),
+		WindGustDirection: reading(float64(p.uint(145, 9)), 360, 1, 0),
+		AirTempC:          reading(float64(p.int(154, 11)), -1024, 0.1, 0),
+		HumidityPct:       reading(float64(p.uint(165, 7)), 101, 1, 0),
+		DewPointC:         reading(float64(p.int(172, 10)), 501, 0.1, 0),
+		PressureHPa:       reading(float64(p.uint(182, 9)), 511, 1, 799),
+		VisibilityNM:      reading(float64(p.uint(194, 7)), 127, 0.1, 0),
+		WaterLevelM:       reading(float64(p.uint(201, 12)), 4001, 0.01, -10),
+		CurrentSpeedKts:   reading(float64(p.uint(215, 8)), 251, 0.1, 0),
+		CurrentDirection:  reading(float64(p.uint(223, 9)), 360, 1, 0),
+		WaveHeightM:       reading(float64(p.uint(276, 8)), 251, 0.1, 0),
+		WaterTempC:        reading(float64(p.int(326, 10)), 501, 0.1, 0),
+	}, nil
+}
+
+// reading scales a raw field value, returning NaN if it's the field's "not available"
+// value (or any value past it, which are reserved)
+func reading(raw, notAvailable, scale, offset float64) float64 {
+	if (notAvailable >= 0 && raw >= notAvailable) || (notAvailable < 0 && raw <= notAvailable) {
+		return math.NaN()
+	}
+	return raw*scale + offset
+}
+
+// observedAt returns the time of the observation. The report carries only the day,
+// hour and minute, so the month and year are taken from the time it was received
+func (r *MetHydroReport) observedAt(received time.Time) time.Time {
+	if r.Day == 0 || r.Hour > 23 || r.Minute > 59 {
+		return received
+	}
+
+	received = received.UTC()
+	observed := time.Date(received.Year(), received.Month(), int(r.Day), int(r.Hour), int(r.Minute), 0, 0, time.UTC)
+	if observed.After(received.Add(time.Hour)) {
+		// reported at the end of last month
+		observed = time.Date(received.Year(), received.Month()-1, int(r.Day), int(r.Hour), int(r.Minute), 0, 0, time.UTC)
+	}
+
+	return observed
+}
+
+type SourcedMetHydroReport struct {
+	MetHydroReport
+	SourceAndTime
+}
+
+// StationReport converts the report to weather station readings
+func (r *SourcedMetHydroReport) StationReport() *weatherdata.StationReport {
+	return &weatherdata.StationReport{
+		StationID:  fmt.Sprintf("AIS %09d", r.MMSI),
+		Source:     r.Source(),
+		Lat:        r.Lat,
+		Lon:        r.Lon,
+		ObservedAt: r.observedAt(r.ReceivedTime()),
+		ReceivedAt: r.ReceivedTime(),
+
+		WindSpeedKts:      r.WindSpeedKts,
+		WindGustKts:       r.WindGustKts,
+		WindDirection:     r.WindDirection,
+		WindGustDirection: r.WindGustDirection,
+		AirTempC:          r.AirTempC,
+		PressureHPa:       r.PressureHPa,
+		VisibilityNM:      r.VisibilityNM,
+		WaterLevelM:       r.WaterLevelM,
+		CurrentSpeedKts:   r.CurrentSpeedKts,
+		CurrentDirection:  r.CurrentDirection,
+		WaterTempC:        r.WaterTempC,
+	}
+}
+
+// UpdateMetHydroReport publishes the report's readings to Weather, if it's set
+func (aisData *AISData) UpdateMetHydroReport(report *SourcedMetHydroReport) {
+	if aisData.Weather == nil {
+		return
+	}
+	aisData.Weather.UpdateStation(report.StationReport())
+}
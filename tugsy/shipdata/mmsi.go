@@ -0,0 +1,429 @@
+package shipdata
+
+import "fmt"
+
+const (
+	InvalidMMSI MMSIClass = iota
+	ShipStation
+	GroupShipStation
+	CoastStation
+	SARAircraft
+	HandheldStation
+	AuxiliaryCraft
+	AidToNavigation
+	SART
+	MOBDevice
+	EPIRB
+)
+
+type MMSIClass int
+
+func (c MMSIClass) String() string {
+	switch c {
+	case ShipStation:
+		return "ship"
+	case GroupShipStation:
+		return "group of ships"
+	case CoastStation:
+		return "coast station"
+	case SARAircraft:
+		return "SAR aircraft"
+	case HandheldStation:
+		return "handheld VHF"
+	case AuxiliaryCraft:
+		return "auxiliary craft"
+	case AidToNavigation:
+		return "aid to navigation"
+	case SART:
+		return "AIS-SART"
+	case MOBDevice:
+		return "man overboard device"
+	case EPIRB:
+		return "EPIRB-AIS"
+	default:
+		return "invalid"
+	}
+}
+
+// MIDInfo is the country or territory a maritime identification digits (MID) block is
+// allocated to. ISO is the country's two letter code, or the name of the flag sprite for
+// territories that don't have one of their own, like "_azores"
+type MIDInfo struct {
+	ISO     string
+	Country string
+}
+
+// MMSIInfo is what can be told about a station from its MMSI alone. MID, ISO and Country
+// are empty for the classes that aren't allocated by country: SARTs, MOB devices and
+// EPIRBs. Valid is false for numbers that aren't a recognized form, whose Class is
+// InvalidMMSI, and for those with a MID that isn't allocated
+type MMSIInfo struct {
+	MMSI    uint32
+	Class   MMSIClass
+	MID     int
+	ISO     string
+	Country string
+	Valid   bool
+}
+
+func (i MMSIInfo) String() string {
+	if i.Country == "" {
+		return fmt.Sprintf("MMSI %09d (%s)", i.MMSI, i.Class)
+	}
+	return fmt.Sprintf("MMSI %09d (%s, %s)", i.MMSI, i.Class, i.Country)
+}
+
+// ClassifyMMSI works out what kind of station an MMSI belongs to and, for those with a
+// MID, the country it's allocated to, following ITU-R M.585:
+//
+//	MIDxxxxxx  ship station
+//	0MIDxxxxx  group of ships
+//	00MIDxxxx  coast station
+//	111MIDxxx  SAR aircraft
+//	8MIDxxxxx  handheld VHF
+//	98MIDxxxx  auxiliary craft of a parent ship
+//	99MIDxxxx  aid to navigation
+//	970xxyyyy  AIS-SART
+//	972xxyyyy  man overboard device
+//	974xxyyyy  EPIRB-AIS
+func ClassifyMMSI(mmsi uint32) MMSIInfo {
+	info := MMSIInfo{MMSI: mmsi}
+	if mmsi == 0 || mmsi > 999999999 {
+		return info
+	}
+
+	var mid int
+	switch {
+	case mmsi < 1000000:
+		// 00MID with a MID starting 2 to 7 is at least 2000000, anything shorter is junk
+		return info
+	case mmsi < 10000000:
+		info.Class, mid = CoastStation, int(mmsi/10000)
+	case mmsi < 100000000:
+		info.Class, mid = GroupShipStation, int(mmsi/100000)
+	case mmsi/1000000 == 111:
+		info.Class, mid = SARAircraft, int(mmsi/1000%1000)
+	case mmsi < 800000000:
+		info.Class, mid = ShipStation, int(mmsi/1000000)
+	case mmsi < 900000000:
+		info.Class, mid = HandheldStation, int(mmsi/100000%1000)
+	case mmsi/10000000 == 98:
+		info.Class, mid = AuxiliaryCraft, int(mmsi/10000%1000)
+	case mmsi/10000000 == 99:
+		info.Class, mid = AidToNavigation, int(mmsi/10000%1000)
+	case mmsi/1000000 == 970:
+		info.Class, info.Valid = SART, true
+		return info
+	case mmsi/1000000 == 972:
+		info.Class, info.Valid = MOBDevice, true
+		return info
+	case mmsi/1000000 == 974:
+		info.Class, info.Valid = EPIRB, true
+		return info
+	default:
+		return info
+	}
+
+	mi, ok := MIDs[mid]
+	if ok == false {
+		return info
+	}
+	info.MID, info.ISO, info.Country, info.Valid = mid, mi.ISO, mi.Country, true
+	return info
+}
+
+// MIDs maps each allocated MID to its country or territory
+var MIDs = map[int]MIDInfo{
+	201: {"AL", "Albania"},
+	202: {"AD", "Andorra"},
+	203: {"AT", "Austria"},
+	204: {"_azores", "Azores (PT)"},
+	205: {"BE", "Belgium"},
+	206: {"BY", "Belarus"},
+	207: {"BG", "Bulgaria"},
+	208: {"VA", "Vatican City State"},
+	209: {"CY", "Cyprus"},
+	210: {"CY", "Cyprus"},
+	211: {"DE", "Germany"},
+	212: {"CY", "Cyprus"},
+	213: {"GE", "Georgia"},
+	214: {"MD", "Moldova"},
+	215: {"MT", "Malta"},
+	216: {"AM", "Armenia"},
+	218: {"DE", "Germany"},
+	219: {"DK", "Denmark"},
+	220: {"DK", "Denmark"},
+	224: {"ES", "Spain"},
+	225: {"ES", "Spain"},
+	226: {"FR", "France"},
+	227: {"FR", "France"},
+	228: {"FR", "France"},
+	229: {"MT", "Malta"},
+	230: {"FI", "Finland"},
+	231: {"FO", "Faroe Islands (DK)"},
+	232: {"GB", "United Kingdom"},
+	233: {"GB", "United Kingdom"},
+	234: {"GB", "United Kingdom"},
+	235: {"GB", "United Kingdom"},
+	236: {"GI", "Gibraltar (UK)"},
+	237: {"GR", "Greece"},
+	238: {"HR", "Croatia"},
+	239: {"GR", "Greece"},
+	240: {"GR", "Greece"},
+	241: {"GR", "Greece"},
+	242: {"MA", "Morocco"},
+	243: {"HU", "Hungary"},
+	244: {"NL", "Netherlands"},
+	245: {"NL", "Netherlands"},
+	246: {"NL", "Netherlands"},
+	247: {"IT", "Italy"},
+	248: {"MT", "Malta"},
+	249: {"MT", "Malta"},
+	250: {"IE", "Ireland"},
+	251: {"IS", "Iceland"},
+	252: {"LI", "Liechtenstein"},
+	253: {"LU", "Luxembourg"},
+	254: {"MC", "Monaco"},
+	255: {"_madeira", "Madeira (PT)"},
+	256: {"MT", "Malta"},
+	257: {"NO", "Norway"},
+	258: {"NO", "Norway"},
+	259: {"NO", "Norway"},
+	261: {"PL", "Poland"},
+	262: {"ME", "Montenegro"},
+	263: {"PT", "Portugal"},
+	264: {"RO", "Romania"},
+	265: {"SE", "Sweden"},
+	266: {"SE", "Sweden"},
+	267: {"SK", "Slovak Republic"},
+	268: {"SM", "San Marino"},
+	269: {"CH", "Switzerland"},
+	270: {"CZ", "Czech Republic"},
+	271: {"TR", "Turkey"},
+	272: {"UA", "Ukraine"},
+	273: {"RU", "Russian Federation"},
+	274: {"MK", "Macedonia"},
+	275: {"LV", "Latvia"},
+	276: {"EE", "Estonia"},
+	277: {"LT", "Lithuania"},
+	278: {"SI", "Slovenia"},
+	279: {"RS", "Serbia"},
+	301: {"AI", "Anguilla (UK)"},
+	303: {"US", "Alaska (US)"},
+	304: {"AG", "Antigua & Barbuda"},
+	305: {"AG", "Antigua & Barbuda"},
+	306: {"NL", "NL Caribbean Islands"},
+	307: {"AW", "Aruba (NL)"},
+	308: {"BS", "Bahamas"},
+	309: {"BS", "Bahamas"},
+	310: {"BM", "Bermuda (UK)"},
+	311: {"BS", "Bahamas"},
+	312: {"BZ", "Belize"},
+	314: {"BB", "Barbados"},
+	316: {"CA", "Canada"},
+	319: {"KY", "Cayman Islands (UK)"},
+	321: {"CR", "Costa Rica"},
+	323: {"CU", "Cuba"},
+	325: {"DM", "Dominica"},
+	327: {"DO", "Dominican Republic"},
+	329: {"GP", "Guadeloupe (FR)"},
+	330: {"GD", "Grenada"},
+	331: {"GL", "Greenland (DK)"},
+	332: {"GT", "Guatemala"},
+	334: {"HN", "Honduras"},
+	336: {"HT", "Haiti"},
+	338: {"US", "United States"},
+	339: {"JM", "Jamaica"},
+	341: {"KN", "St. Kitts & Nevis"},
+	343: {"LC", "St. Lucia"},
+	345: {"MX", "Mexico"},
+	347: {"MQ", "Martinique (FR)"},
+	348: {"MS", "Montserrat (UK)"},
+	350: {"NI", "Nicaragua"},
+	351: {"PA", "Panama"},
+	352: {"PA", "Panama"},
+	353: {"PA", "Panama"},
+	354: {"PA", "Panama"},
+	355: {"PA", "Panama"},
+	356: {"PA", "Panama"},
+	357: {"PA", "Panama"},
+	358: {"PR", "Puerto Rico (US)"},
+	359: {"SV", "El Salvador"},
+	361: {"PM", "St. Pierre & Miquelon (FR)"},
+	362: {"TT", "Trinidad & Tobago"},
+	364: {"TC", "Turks & Caicos (UK)"},
+	366: {"US", "United States"},
+	367: {"US", "United States"},
+	368: {"US", "United States"},
+	369: {"US", "United States"},
+	370: {"PA", "Panama"},
+	371: {"PA", "Panama"},
+	372: {"PA", "Panama"},
+	373: {"PA", "Panama"},
+	374: {"PA", "Panama"},
+	375: {"VC", "St. Vincent & the Grenadines"},
+	376: {"VC", "St. Vincent & the Grenadines"},
+	377: {"VC", "St. Vincent & the Grenadines"},
+	378: {"VG", "Virgin Islands (UK)"},
+	379: {"VI", "Virgin Islands (US)"},
+	401: {"AF", "Afghanistan"},
+	403: {"SA", "Saudi Arabia"},
+	405: {"BD", "Bangladesh"},
+	408: {"BH", "Bahrain"},
+	410: {"BT", "Bhutan"},
+	412: {"CN", "China"},
+	413: {"CN", "China"},
+	414: {"CN", "China"},
+	416: {"TW", "Taiwan (CN)"},
+	417: {"LK", "Sri Lanka"},
+	419: {"IN", "India"},
+	422: {"IR", "Iran"},
+	423: {"AZ", "Azerbaijan"},
+	425: {"IQ", "Iraq"},
+	428: {"IL", "Israel"},
+	431: {"JP", "Japan"},
+	432: {"JP", "Japan"},
+	434: {"TM", "Turkmenistan"},
+	436: {"KZ", "Kazakhstan"},
+	437: {"UZ", "Uzbekistan"},
+	438: {"JO", "Jordan"},
+	440: {"KR", "Korea"},
+	441: {"KR", "Korea"},
+	443: {"_palestine", "State of Palestine"},
+	445: {"KP", "Korea (DPR)"},
+	447: {"KW", "Kuwait"},
+	450: {"LB", "Lebanon"},
+	451: {"KG", "Kyrgyz Republic"},
+	453: {"MO", "Macao (CN)"},
+	455: {"MV", "Maldives"},
+	457: {"MN", "Mongolia"},
+	459: {"NP", "Nepal"},
+	461: {"OM", "Oman"},
+	463: {"PK", "Pakistan"},
+	466: {"QA", "Qatar"},
+	468: {"SY", "Syria"},
+	470: {"AE", "United Arab Emirates"},
+	471: {"AE", "United Arab Emirates"},
+	472: {"TJ", "Tajikistan"},
+	473: {"YE", "Yemen"},
+	475: {"YE", "Yemen"},
+	477: {"HK", "Hong Kong (CN)"},
+	478: {"BA", "Bosnia & Herzegovina"},
+	501: {"_adelie", "Adelie Land (FR)"},
+	503: {"AU", "Australia"},
+	506: {"MM", "Myanmar"},
+	508: {"BN", "Brunei Darussalam"},
+	510: {"FM", "Micronesia"},
+	511: {"PW", "Palau"},
+	512: {"NZ", "New Zealand"},
+	514: {"KH", "Cambodia"},
+	515: {"KH", "Cambodia"},
+	516: {"CX", "Christmas Island (AU)"},
+	518: {"CK", "Cook Islands (NZ)"},
+	520: {"FJ", "Fiji"},
+	523: {"CC", "Cocos Islands (AU)"},
+	525: {"ID", "Indonesia"},
+	529: {"KI", "Kiribati"},
+	531: {"LA", "Laos"},
+	533: {"MY", "Malaysia"},
+	536: {"MP", "Northern Mariana Islands (US)"},
+	538: {"MH", "Marshall Islands"},
+	540: {"NC", "New Caledonia (FR)"},
+	542: {"NU", "Niue (NZ)"},
+	544: {"NR", "Nauru"},
+	546: {"PF", "French Polynesia (FR)"},
+	548: {"PH", "Philippines"},
+	550: {"TL", "Timor-Leste"},
+	553: {"PG", "Papua New Guinea"},
+	555: {"PN", "Pitcairn Island (UK)"},
+	557: {"SB", "Solomon Islands"},
+	559: {"AS", "American Samoa (US)"},
+	561: {"WS", "Samoa"},
+	563: {"SG", "Singapore"},
+	564: {"SG", "Singapore"},
+	565: {"SG", "Singapore"},
+	566: {"SG", "Singapore"},
+	567: {"TH", "Thailand"},
+	570: {"TO", "Tonga"},
+	572: {"TV", "Tuvalu"},
+	574: {"VN", "Viet Nam"},
+	576: {"VU", "Vanuatu"},
+	577: {"VU", "Vanuatu"},
+	578: {"WF", "Wallis & Futuna Islands (FR)"},
+	601: {"ZA", "South Africa"},
+	603: {"AO", "Angola"},
+	605: {"DZ", "Algeria"},
+	607: {"_stpaul", "St. Paul, Amsterdam Islands (FR)"},
+	608: {"SH", "Ascension Island (UK)"},
+	609: {"BI", "Burundi"},
+	610: {"BJ", "Benin"},
+	611: {"BW", "Botswana"},
+	612: {"CF", "Central African Republic"},
+	613: {"CM", "Cameroon"},
+	615: {"CG", "Congo"},
+	616: {"KM", "Comoros"},
+	617: {"CV", "Cabo Verde"},
+	618: {"_crozet", "Crozet Archipelago (FR)"},
+	619: {"CI", "Cote d'Ivoire"},
+	620: {"KM", "Comoros"},
+	621: {"DJ", "Djibouti"},
+	622: {"EG", "Egypt"},
+	624: {"ET", "Ethiopia"},
+	625: {"ER", "Eritrea"},
+	626: {"GA", "Gabonese Republic"},
+	627: {"GH", "Ghana"},
+	629: {"GM", "Gambia"},
+	630: {"GW", "Guinea-Bissau"},
+	631: {"GQ", "Equatorial Guinea"},
+	632: {"GN", "Guinea"},
+	633: {"BF", "Burkina Faso"},
+	634: {"KE", "Kenya"},
+	635: {"_kerguelen", "Kerguelen Islands (FR)"},
+	636: {"LR", "Liberia"},
+	637: {"LR", "Liberia"},
+	638: {"SS", "South Sudan"},
+	642: {"LY", "Libya"},
+	644: {"LS", "Lesotho"},
+	645: {"MU", "Mauritius"},
+	647: {"MG", "Madagascar"},
+	649: {"ML", "Mali"},
+	650: {"MZ", "Mozambique"},
+	654: {"MR", "Mauritania"},
+	655: {"MW", "Malawi"},
+	656: {"NE", "Niger"},
+	657: {"NG", "Nigeria"},
+	659: {"NA", "Namibia"},
+	660: {"_reunion", "Reunion (FR)"},
+	661: {"RW", "Rwanda"},
+	662: {"SD", "Sudan"},
+	663: {"SN", "Senegal"},
+	664: {"SC", "Seychelles"},
+	665: {"SH", "St. Helena (UK)"},
+	666: {"SO", "Somalia"},
+	667: {"SL", "Sierra Leone"},
+	668: {"ST", "Sao Tome & Principe"},
+	669: {"SZ", "Swaziland"},
+	670: {"TD", "Chad"},
+	671: {"TG", "Togolese Republic"},
+	672: {"TN", "Tunisia"},
+	674: {"TZ", "Tanzania"},
+	675: {"UG", "Uganda"},
+	676: {"CD", "Congo"},
+	677: {"TZ", "Tanzania"},
+	678: {"ZM", "Zambia"},
+	679: {"ZW", "Zimbabwe"},
+	701: {"AR", "Argentina"},
+	710: {"BR", "Brazil"},
+	720: {"BO", "Bolivia"},
+	725: {"CL", "Chile"},
+	730: {"CO", "Colombia"},
+	735: {"EC", "Ecuador"},
+	740: {"FK", "Falkland Islands (UK)"},
+	745: {"GF", "Guiana (FR)"},
+	750: {"GY", "Guyana"},
+	755: {"PY", "Paraguay"},
+	760: {"PE", "Peru"},
+	765: {"SR", "Suriname"},
+	770: {"UY", "Uruguay"},
+	775: {"VE", "Venezuela"},
+}
@@ -0,0 +1,42 @@
+package shipdata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyMMSI(t *testing.T) {
+	tests := []struct {
+		mmsi  uint32
+		class MMSIClass
+		iso   string
+		valid bool
+	}{
+		{367123450, ShipStation, "US", true},
+		{36712345, GroupShipStation, "US", true},
+		{3669999, CoastStation, "US", true},
+		{111367100, SARAircraft, "US", true},
+		{993671234, AidToNavigation, "US", true},
+		{982041234, AuxiliaryCraft, "_azores", true},
+		{970123456, SART, "", true},
+		{972123456, MOBDevice, "", true},
+		{974123456, EPIRB, "", true},
+		{251000001, ShipStation, "IS", true},
+		{199000001, ShipStation, "", false},
+		{123456789, ShipStation, "", false},
+		{0, InvalidMMSI, "", false},
+		{12345, InvalidMMSI, "", false},
+		{960000000, InvalidMMSI, "", false},
+		{1000000000, InvalidMMSI, "", false},
+	}
+
+	for _, test := range tests {
+		info := ClassifyMMSI(test.mmsi)
+		assert.Equal(t, test.class, info.Class, "class of %d", test.mmsi)
+		assert.Equal(t, test.iso, info.ISO, "ISO code of %d", test.mmsi)
+		assert.Equal(t, test.valid, info.Valid, "validity of %d", test.mmsi)
+	}
+
+	assert.Equal(t, "United States", ClassifyMMSI(367123450).Country)
+}
@@ -0,0 +1,122 @@
+package shipdata
+
+import (
+	"math"
+
+	"github.com/andmarios/aislib"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	maxPlausibleSpeedKts = 60  // faster than anything in the bay, high speed ferries included
+	outlierSlackMeters   = 100 // moves this small are always plausible, whatever the timing
+	sogToleranceFactor   = 1.5 // allowance over the reported speed for acceleration & error
+	sogToleranceKts      = 5
+	cogCheckMeters       = 500 // moves shorter than this aren't checked against the course
+	cogCheckMinSpeedKts  = 3   // nor are those of vessels going slower than this
+	metersPerSecPerKt    = 0.514
+	confirmReports       = 2 // consecutive reports that confirm a jump is real
+)
+
+// The outlier filter: every new position is checked against the latest one in the track.
+// A position that implies an impossible speed, or one that's far from where the reported
+// speed and course say the vessel should be, is quarantined rather than added. If the
+// reports that follow agree with the quarantined position rather than the track, the
+// vessel really has moved (e.g. it was out of range for a while) and the quarantined
+// positions are added after all. Otherwise they're discarded as outliers
+
+// plausibleMove returns true if a vessel at prev could have reached next
+func plausibleMove(prev, next Positionable) bool {
+	pp, np := prev.GetPositionReport(), next.GetPositionReport()
+	meters := distanceMeters(pp.Lat, pp.Lon, np.Lat, np.Lon)
+	if meters <= outlierSlackMeters {
+		return true
+	}
+
+	secs := next.ReceivedTime().Sub(prev.ReceivedTime()).Seconds()
+	if secs < 1 {
+		secs = 1
+	}
+
+	speedKts := float64(maxPlausibleSpeedKts)
+	if sog, ok := reportedSpeed(pp, np); ok {
+		speedKts = math.Min(speedKts, sog*sogToleranceFactor+sogToleranceKts)
+	}
+	if meters > outlierSlackMeters+secs*speedKts*metersPerSecPerKt {
+		return false
+	}
+
+	// a long move against both reported courses is a jump, not a turn
+	if meters > cogCheckMeters && pp.Speed >= cogCheckMinSpeedKts && np.Speed >= cogCheckMinSpeedKts &&
+		pp.Course < 360 && np.Course < 360 && pp.Speed < 102.3 && np.Speed < 102.3 {
+
+		bearing := bearingDegrees(pp.Lat, pp.Lon, np.Lat, np.Lon)
+		if angleBetween(bearing, float64(pp.Course)) > 90 && angleBetween(bearing, float64(np.Course)) > 90 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reportedSpeed returns the higher of the two reported speeds over ground, or false if
+// neither report has one
+func reportedSpeed(reports ...*aislib.PositionReport) (float64, bool) {
+	var sog float64
+	var ok bool
+	for _, r := range reports {
+		if r.Speed < 102.3 { // 102.3 is "not available"
+			sog = math.Max(sog, float64(r.Speed))
+			ok = true
+		}
+	}
+	return sog, ok
+}
+
+// validPosition returns false for the "not available" position (91, 181) and anything
+// else off the globe
+func validPosition(report *aislib.PositionReport) bool {
+	return math.Abs(report.Lat) <= 90 && math.Abs(report.Lon) <= 180
+}
+
+// filterOutlier decides what happens to a new position, returning the positions to add
+// to the track: none, the new one, or the quarantined ones it confirms. Called with the
+// ShipHistory locked
+func (h *ShipHistory) filterOutlier(report Positionable) []Positionable {
+	if validPosition(report.GetPositionReport()) == false {
+		h.outliers++
+		return nil
+	}
+
+	if len(h.positions) == 0 || plausibleMove(h.positions[len(h.positions)-1], report) {
+		if len(h.quarantine) > 0 {
+			logger.Debugf("discarding %d outlying positions for MMSI %d", len(h.quarantine), h.MMSI)
+			h.outliers += len(h.quarantine)
+			h.quarantine = nil
+		}
+		return []Positionable{report}
+	}
+
+	if len(h.quarantine) > 0 && plausibleMove(h.quarantine[len(h.quarantine)-1], report) {
+		h.quarantine = append(h.quarantine, report)
+		if len(h.quarantine) >= confirmReports {
+			logger.Debugf("accepting a jump in position for MMSI %d", h.MMSI)
+			confirmed := h.quarantine
+			h.quarantine = nil
+			return confirmed
+		}
+		return nil
+	}
+
+	// a jump away from both the track and anything already quarantined
+	h.outliers += len(h.quarantine)
+	h.quarantine = []Positionable{report}
+	return nil
+}
+
+// Outliers returns the number of positions that have been discarded as outliers
+func (h *ShipHistory) Outliers() int {
+	h.Lock()
+	defer h.Unlock()
+	return h.outliers
+}
@@ -0,0 +1,56 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutlierIsDiscarded(t *testing.T) {
+	now := time.Now()
+	sh := NewShipHistory(1)
+
+	sh.addPosition(positionAt(41.80, -71.39, now))
+	sh.addPosition(positionAt(41.801, -71.39, now.Add(10*time.Second)))
+	// ten kilometers in ten seconds
+	sh.addPosition(positionAt(41.90, -71.39, now.Add(20*time.Second)))
+	sh.addPosition(positionAt(41.802, -71.39, now.Add(30*time.Second)))
+
+	assert.Equal(t, 3, len(sh.positions))
+	assert.InDelta(t, 41.802, sh.positions[2].GetPositionReport().Lat, 0.000001)
+	assert.Equal(t, 1, sh.Outliers())
+
+	// as is the "not available" position
+	sh.addPosition(positionAt(91, 181, now.Add(40*time.Second)))
+	assert.Equal(t, 3, len(sh.positions))
+	assert.Equal(t, 2, sh.Outliers())
+}
+
+func TestConfirmedJumpIsAccepted(t *testing.T) {
+	now := time.Now()
+	sh := NewShipHistory(1)
+
+	sh.addPosition(positionAt(41.80, -71.39, now))
+	sh.addPosition(positionAt(41.90, -71.39, now.Add(10*time.Second)))
+	assert.Equal(t, 1, len(sh.positions))
+
+	// the next report agrees with the jump rather than the track
+	sh.addPosition(positionAt(41.9005, -71.39, now.Add(20*time.Second)))
+	assert.Equal(t, 3, len(sh.positions))
+	assert.InDelta(t, 41.9005, sh.positions[2].GetPositionReport().Lat, 0.000001)
+	assert.Equal(t, 0, sh.Outliers())
+}
+
+func TestReportedCourseCatchesJumps(t *testing.T) {
+	now := time.Now()
+	heading := func(lat float64, at time.Time) *MockPositionReport {
+		p := positionAt(lat, -71.39, at)
+		p.positionReport.Speed, p.positionReport.Course = 20, 0
+		return p
+	}
+
+	// a kilometer in two minutes is plausible at 20 knots, but not backwards
+	assert.True(t, plausibleMove(heading(41.80, now), heading(41.809, now.Add(2*time.Minute))))
+	assert.False(t, plausibleMove(heading(41.80, now), heading(41.791, now.Add(2*time.Minute))))
+}
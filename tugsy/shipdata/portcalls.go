@@ -0,0 +1,171 @@
+package shipdata
+
+import (
+	"sync"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	Arrival PortCallType = iota
+	Departure
+)
+
+const (
+	defaultPortCallLogSize = 20
+	moorDwell              = 5 * time.Minute // stopped for this long in a berth is an arrival
+	stoppedSpeedKts        = 0.5
+	departureSpeedKts      = 2
+)
+
+type PortCallType int
+
+func (t PortCallType) String() string {
+	switch t {
+	case Arrival:
+		return "ARR"
+	case Departure:
+		return "DEP"
+	default:
+		return "???"
+	}
+}
+
+// A PortCall is a vessel arriving at or departing from a berth. Berth is empty if the
+// vessel reported itself moored outside of any berth zone. Destination is the vessel's
+// destination at the time, normalized
+type PortCall struct {
+	Type        PortCallType
+	MMSI        uint32
+	VesselName  string
+	Berth       string
+	Destination Destination
+	At          time.Time
+}
+
+// a vessel's progress through a port call
+type portCallState struct {
+	stoppedSince time.Time // zero while moving
+	atBerth      bool
+	berth        string
+}
+
+// PortCallDetector infers arrivals and departures from vessels' positions. A vessel
+// arrives when it reports itself moored, or when it has been stopped in a berth zone for
+// a few minutes. It departs when it's under way again, moving and out of its berth.
+// Service craft -- tugs, pilots, tenders and the like -- come and go all day and aren't
+// counted. The latest arrivals and departures are kept in a rolling log
+type PortCallDetector struct {
+	sync.Mutex
+
+	LogSize int
+
+	states map[uint32]*portCallState
+	log    []PortCall // oldest first
+}
+
+func NewPortCallDetector() *PortCallDetector {
+	return &PortCallDetector{LogSize: defaultPortCallLogSize, states: make(map[uint32]*portCallState)}
+}
+
+// Recent returns the logged arrivals and departures, newest first
+func (d *PortCallDetector) Recent() []PortCall {
+	d.Lock()
+	defer d.Unlock()
+
+	calls := make([]PortCall, len(d.log))
+	for i, call := range d.log {
+		calls[len(d.log)-1-i] = call
+	}
+	return calls
+}
+
+// evaluate updates the vessel's port call state with a new position. berths are the
+// berth zones the vessel is in. Returns the port call the position completes, if any
+func (d *PortCallDetector) evaluate(position Positionable, voyageData *SourcedStaticVoyageData, berths []*Zone) (PortCall, bool) {
+	if voyageData != nil && isServiceCraft(voyageData.ShipType) {
+		return PortCall{}, false
+	}
+
+	report := position.GetPositionReport()
+	at := position.ReceivedTime()
+
+	status := -1
+	if classA, ok := position.(*SourcedClassAPositionReport); ok {
+		status = int(classA.Status)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	stopped := report.Speed < stoppedSpeedKts
+	berth := ""
+	if len(berths) > 0 {
+		berth = berths[0].Name
+	}
+
+	// a vessel seen for the first time already alongside -- on startup, say -- arrived
+	// some time ago, and isn't logged as arriving now
+	state, ok := d.states[report.MMSI]
+	if ok == false {
+		state = &portCallState{}
+		if status == navStatusMoored || (berth != "" && stopped) {
+			state.atBerth, state.berth = true, berth
+		}
+		d.states[report.MMSI] = state
+	}
+
+	if stopped == false {
+		state.stoppedSince = time.Time{}
+	} else if state.stoppedSince.IsZero() {
+		state.stoppedSince = at
+	}
+
+	var call PortCall
+	switch {
+	case state.atBerth == false && (status == navStatusMoored ||
+		(berth != "" && stopped && at.Sub(state.stoppedSince) >= moorDwell)):
+
+		state.atBerth, state.berth = true, berth
+		call = PortCall{Type: Arrival, Berth: berth}
+
+	case state.atBerth && status != navStatusMoored && report.Speed >= departureSpeedKts &&
+		report.Speed < 102.3 && (state.berth == "" || berth != state.berth):
+
+		state.atBerth = false
+		call = PortCall{Type: Departure, Berth: state.berth}
+
+	default:
+		return PortCall{}, false
+	}
+
+	call.MMSI = report.MMSI
+	call.At = at
+	if voyageData != nil {
+		call.VesselName = voyageData.VesselName
+		call.Destination = NormalizeDestination(voyageData.Destination)
+	}
+
+	logger.Debugf("MMSI %d %s %s", call.MMSI, call.Type, call.Berth)
+	d.log = append(d.log, call)
+	if len(d.log) > d.LogSize {
+		d.log = d.log[len(d.log)-d.LogSize:]
+	}
+	return call, true
+}
+
+// forget drops the port call state of a vessel that's no longer being tracked. Its
+// logged calls are kept
+func (d *PortCallDetector) forget(mmsi uint32) {
+	d.Lock()
+	defer d.Unlock()
+	delete(d.states, mmsi)
+}
+
+// isServiceCraft returns true for the ship types that work in the harbor rather than
+// call at it: towing, pilots, SAR, tugs, tenders, anti-pollution, law enforcement and
+// medical
+func isServiceCraft(shipType uint8) bool {
+	return shipType == 31 || shipType == 32 || (shipType >= 50 && shipType <= 59)
+}
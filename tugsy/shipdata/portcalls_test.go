@@ -0,0 +1,84 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andmarios/aislib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrivalAndDeparture(t *testing.T) {
+	berth := &Zone{
+		Name:   "berth",
+		Points: [][]float64{{41.80, -71.40}, {41.80, -71.39}, {41.79, -71.39}, {41.79, -71.40}},
+		Berth:  true,
+	}
+	geofences, err := NewGeofences([]*Zone{berth})
+	assert.NoError(t, err)
+
+	aisData := NewAISData()
+	aisData.Geofences = geofences
+	aisData.PortCalls = NewPortCallDetector()
+
+	now := time.Now()
+	position := func(mmsi uint32, lat float64, speed float32, at time.Time) *SourcedClassAPositionReport {
+		return &SourcedClassAPositionReport{
+			aislib.ClassAPositionReport{PositionReport: aislib.PositionReport{MMSI: mmsi, Lat: lat, Lon: -71.395, Speed: speed, Course: 0}},
+			SourceAndTime{receivedTime: at},
+		}
+	}
+
+	aisData.UpdateStaticVoyageData(&SourcedStaticVoyageData{aislib.StaticVoyageData{MMSI: 1, VesselName: "NORTHERN STAR", ShipType: 70}, SourceAndTime{}})
+	aisData.AddPosition(position(1, 41.7950, 3, now))
+	aisData.AddPosition(position(1, 41.7950, 0, now.Add(1*time.Minute)))
+	aisData.AddPosition(position(1, 41.7950, 0, now.Add(2*time.Minute)))
+	assert.Equal(t, 0, len(aisData.PortCalls.Recent()))
+
+	aisData.AddPosition(position(1, 41.7950, 0, now.Add(6*time.Minute)))
+	aisData.AddPosition(position(1, 41.7950, 0, now.Add(8*time.Minute)))
+	aisData.AddPosition(position(1, 41.8010, 8, now.Add(14*time.Minute)))
+
+	calls := aisData.PortCalls.Recent()
+	assert.Equal(t, 2, len(calls))
+	assert.Equal(t, Departure, calls[0].Type)
+	assert.Equal(t, Arrival, calls[1].Type)
+	assert.Equal(t, "NORTHERN STAR", calls[1].VesselName)
+	assert.Equal(t, "berth", calls[1].Berth)
+	assert.Equal(t, now.Add(6*time.Minute), calls[1].At)
+
+	// tugs stop at berths all day
+	aisData.UpdateStaticVoyageData(&SourcedStaticVoyageData{aislib.StaticVoyageData{MMSI: 2, ShipType: 52}, SourceAndTime{}})
+	aisData.AddPosition(position(2, 41.7950, 0, now))
+	aisData.AddPosition(position(2, 41.7950, 0, now.Add(10*time.Minute)))
+	assert.Equal(t, 2, len(aisData.PortCalls.Recent()))
+}
+
+func TestAlreadyAlongsideIsNotAnArrival(t *testing.T) {
+	detector := NewPortCallDetector()
+	berths := []*Zone{{Name: "berth", Berth: true}}
+
+	now := time.Now()
+	moored := &SourcedClassAPositionReport{
+		aislib.ClassAPositionReport{PositionReport: aislib.PositionReport{MMSI: 1, Speed: 0}, Status: navStatusMoored},
+		SourceAndTime{receivedTime: now},
+	}
+	stopped := NewMockPositionReport(now, 2)
+
+	// first seen moored, or stopped in a berth: it's been there since before we started
+	_, ok := detector.evaluate(moored, nil, nil)
+	assert.False(t, ok)
+	_, ok = detector.evaluate(stopped, nil, berths)
+	assert.False(t, ok)
+	_, ok = detector.evaluate(NewMockPositionReport(now.Add(10*time.Minute), 2), nil, berths)
+	assert.False(t, ok)
+	assert.Equal(t, 0, len(detector.Recent()))
+
+	// but it's logged when it leaves
+	leaving := NewMockPositionReport(now.Add(20*time.Minute), 2)
+	leaving.positionReport.Speed = 8
+	call, ok := detector.evaluate(leaving, nil, nil)
+	assert.True(t, ok)
+	assert.Equal(t, Departure, call.Type)
+	assert.Equal(t, "berth", call.Berth)
+}
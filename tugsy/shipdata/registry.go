@@ -0,0 +1,215 @@
+package shipdata
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/andmarios/aislib"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRegistryInterval  = 5 * time.Minute
+	defaultRegistryRetention = 90 * 24 * time.Hour
+
+	// the source of voyage data seeded from the registry
+	registrySource = "registry"
+)
+
+// A RegisteredVessel is what's known about a vessel that doesn't change from one voyage
+// to the next
+type RegisteredVessel struct {
+	MMSI        uint32
+	IMO         uint32 `json:",omitempty"`
+	Name        string
+	Callsign    string
+	ShipType    uint8
+	ToBow       uint16
+	ToStern     uint16
+	ToPort      uint8
+	ToStarboard uint8
+	Updated     time.Time // when the static data was last received
+	LastSeen    time.Time // when anything was last received from the vessel
+}
+
+// voyageData returns the vessel's static data as voyage data, without any of the
+// voyage: no destination, ETA or draught
+func (v *RegisteredVessel) voyageData() *SourcedStaticVoyageData {
+	return &SourcedStaticVoyageData{
+		aislib.StaticVoyageData{
+			MMSI:        v.MMSI,
+			IMO:         v.IMO,
+			Callsign:    v.Callsign,
+			VesselName:  v.Name,
+			ShipType:    v.ShipType,
+			ToBow:       v.ToBow,
+			ToStern:     v.ToStern,
+			ToPort:      v.ToPort,
+			ToStarboard: v.ToStarboard,
+		},
+		SourceAndTime{sourceName: registrySource, receivedTime: v.Updated},
+	}
+}
+
+// VesselRegistry remembers the static data of every vessel seen, long after its
+// ShipHistory has been pruned, so that a vessel that returns is known straight away.
+// Vessels not seen for Retention are forgotten when the registry is saved
+type VesselRegistry struct {
+	sync.Mutex
+
+	Retention time.Duration
+
+	// if set, retention is measured against this clock rather than the wall clock
+	Clock Clock
+
+	vessels map[uint32]*RegisteredVessel
+}
+
+func NewVesselRegistry() *VesselRegistry {
+	return &VesselRegistry{Retention: defaultRegistryRetention, vessels: make(map[uint32]*RegisteredVessel)}
+}
+
+// LoadVesselRegistry reads the registry saved at path. A missing file isn't an error:
+// the registry just starts empty
+func LoadVesselRegistry(path string) (*VesselRegistry, error) {
+	registry := NewVesselRegistry()
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		logger.Infof("No vessel registry at %s, starting empty", path)
+		return registry, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var vessels []*RegisteredVessel
+	if err := json.NewDecoder(f).Decode(&vessels); err != nil {
+		return nil, err
+	}
+	for _, v := range vessels {
+		registry.vessels[v.MMSI] = v
+	}
+
+	logger.Infof("Loaded %d vessels from the registry at %s", len(vessels), path)
+	return registry, nil
+}
+
+// Vessel returns a copy of the registered vessel/true, or nil/false if it isn't known
+func (r *VesselRegistry) Vessel(mmsi uint32) (*RegisteredVessel, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	v, ok := r.vessels[mmsi]
+	if ok == false {
+		return nil, false
+	}
+	vCopy := *v
+	return &vCopy, true
+}
+
+// update registers the static parts of the voyage data
+func (r *VesselRegistry) update(data *SourcedStaticVoyageData) {
+	r.Lock()
+	defer r.Unlock()
+
+	v, ok := r.vessels[data.MMSI]
+	if ok == false {
+		v = &RegisteredVessel{MMSI: data.MMSI}
+		r.vessels[data.MMSI] = v
+	}
+
+	v.IMO = data.IMO
+	v.Name = data.VesselName
+	v.Callsign = data.Callsign
+	v.ShipType = data.ShipType
+	v.ToBow, v.ToStern, v.ToPort, v.ToStarboard = data.ToBow, data.ToStern, data.ToPort, data.ToStarboard
+	v.Updated = data.ReceivedTime()
+	if v.Updated.After(v.LastSeen) {
+		v.LastSeen = v.Updated
+	}
+}
+
+// seen records that a registered vessel was heard from at
+func (r *VesselRegistry) seen(mmsi uint32, at time.Time) {
+	r.Lock()
+	defer r.Unlock()
+
+	if v, ok := r.vessels[mmsi]; ok && at.After(v.LastSeen) {
+		v.LastSeen = at
+	}
+}
+
+// Save writes the registry to path, dropping the vessels that haven't been seen within
+// Retention. Like snapshots, it's written alongside and renamed into place
+func (r *VesselRegistry) Save(path string) error {
+	now := time.Now()
+	if r.Clock != nil {
+		now = r.Clock.Now()
+	}
+
+	r.Lock()
+	since := now.Add(-r.Retention)
+	vessels := make([]RegisteredVessel, 0, len(r.vessels))
+	for mmsi, v := range r.vessels {
+		if v.LastSeen.Before(since) {
+			delete(r.vessels, mmsi)
+			continue
+		}
+		vessels = append(vessels, *v)
+	}
+	r.Unlock()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if err := json.NewEncoder(tmp).Encode(vessels); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	logger.Debugf("Wrote %d vessels to the registry at %s", len(vessels), path)
+	return os.Rename(tmp.Name(), path)
+}
+
+// SavePeriodically saves the registry to path every interval until ctx is cancelled,
+// then saves it one last time
+func (r *VesselRegistry) SavePeriodically(ctx context.Context, path string, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRegistryInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := r.Save(path); err != nil {
+				logger.WithError(err).Error("saving the vessel registry")
+			}
+			logger.Info("vessel registry loop exiting")
+			return
+
+		case <-ticker.C:
+			if err := r.Save(path); err != nil {
+				logger.WithError(err).Warn("saving the vessel registry")
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package shipdata
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andmarios/aislib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrySeedsNewHistories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tugsy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "registry.json")
+
+	now := time.Now()
+	aisData := NewAISData()
+	aisData.Registry = NewVesselRegistry()
+	aisData.UpdateStaticVoyageData(&SourcedStaticVoyageData{
+		aislib.StaticVoyageData{MMSI: 1, IMO: 9123456, VesselName: "MV BLOCK ISLAND", ShipType: 60, ToBow: 40, ToStern: 20, Destination: "NEW SHOREHAM"},
+		SourceAndTime{receivedTime: now.Add(-time.Hour)},
+	})
+	aisData.AddPosition(positionAt(41.8, -71.39, now))
+	aisData.Registry.seen(2, now) // never registered, so not added
+	assert.NoError(t, aisData.Registry.Save(path))
+
+	registry, err := LoadVesselRegistry(path)
+	assert.NoError(t, err)
+	vessel, ok := registry.Vessel(1)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(9123456), vessel.IMO)
+	assert.Equal(t, now.Add(-time.Hour).Unix(), vessel.Updated.Unix())
+	assert.Equal(t, now.Unix(), vessel.LastSeen.Unix())
+	_, ok = registry.Vessel(2)
+	assert.False(t, ok)
+
+	// the next day the ship's history is gone, but it's recognized from its first position
+	aisData = NewAISData()
+	aisData.Registry = registry
+	aisData.AddPosition(positionAt(41.8, -71.39, now))
+	history, _ := aisData.ShipHistory(1)
+	assert.Equal(t, "MV BLOCK ISLAND", history.VoyageData().VesselName)
+	assert.Equal(t, uint8(60), history.VoyageData().ShipType)
+	assert.Equal(t, "", history.VoyageData().Destination)
+	assert.Equal(t, registrySource, history.VoyageData().Source())
+
+	// a missing registry is an empty one, and vessels not seen in a while are dropped
+	registry, err = LoadVesselRegistry(filepath.Join(dir, "missing.json"))
+	assert.NoError(t, err)
+	registry.Retention = time.Hour
+	registry.update(&SourcedStaticVoyageData{aislib.StaticVoyageData{MMSI: 3}, SourceAndTime{receivedTime: now.Add(-2 * time.Hour)}})
+	assert.NoError(t, registry.Save(path))
+	_, ok = registry.Vessel(3)
+	assert.False(t, ok)
+}
+
+func TestPrunedShipIsSeededOnReturn(t *testing.T) {
+	aisData := NewAISData()
+	aisData.Registry = NewVesselRegistry()
+	aisData.PositionRetentionDur = time.Minute
+	aisData.PositionCullingInterval = 10 * time.Millisecond
+	pruned := aisData.Subscribe(ChangeTypes(VesselPruned))
+
+	long := time.Now().Add(-time.Hour)
+	aisData.UpdateStaticVoyageData(&SourcedStaticVoyageData{
+		aislib.StaticVoyageData{MMSI: 1, VesselName: "MV BLOCK ISLAND", ShipType: 60, Destination: "NEW SHOREHAM"},
+		SourceAndTime{receivedTime: long},
+	})
+	aisData.AddPosition(positionAt(41.8, -71.39, long))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go aisData.PrunePositions(ctx)
+	select {
+	case <-pruned:
+	case <-time.After(time.Second):
+		t.Fatal("the stale ship was never pruned")
+	}
+	cancel()
+
+	// back again: the history is new, but its static data comes from the registry
+	aisData.AddPosition(positionAt(41.8, -71.39, time.Now()))
+	history, ok := aisData.ShipHistory(1)
+	assert.True(t, ok)
+	assert.Equal(t, "MV BLOCK ISLAND", history.VoyageData().VesselName)
+	assert.Equal(t, "", history.VoyageData().Destination)
+	assert.Equal(t, registrySource, history.VoyageData().Source())
+}
@@ -0,0 +1,308 @@
+package shipdata
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andmarios/aislib"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultReplaySpeed = 1.0
+	replayMaxWait      = 1 * time.Minute        // gaps longer than this in the recording are skipped over
+	replayLoopGap      = 10 * time.Second       // between the end of a recording and its next loop
+	replaySpacing      = 100 * time.Millisecond // between sentences when the recording has no times
+	replayMaxUntimed   = 1000                   // sentences held waiting for the next time before they're spaced out
+)
+
+// ReplayClock runs at a multiple of the wall clock's speed from a time in the past, so
+// that a recording plays back against its own timestamps. It tells the wall clock's time
+// until the replay sets it
+type ReplayClock struct {
+	sync.Mutex
+
+	speed    float64
+	base     time.Time // the replay's time at wallBase
+	wallBase time.Time
+}
+
+func NewReplayClock(speed float64) *ReplayClock {
+	if speed <= 0 {
+		speed = defaultReplaySpeed
+	}
+	return &ReplayClock{speed: speed}
+}
+
+func (c *ReplayClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.base.IsZero() {
+		return time.Now()
+	}
+	return c.base.Add(time.Duration(float64(time.Since(c.wallBase)) * c.speed))
+}
+
+// set jumps the clock to t
+func (c *ReplayClock) set(t time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.base, c.wallBase = t, time.Now()
+}
+
+func (c *ReplayClock) running() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.base.IsZero() == false
+}
+
+// ReplaySource plays back a recording of NMEA sentences, gzipped or not, at the pace it
+// was recorded, sped up by the clock's speed. A sentence's time comes from its tag block
+// or, for recordings without them, from the base station reports in the recording: the
+// sentences in between are spread out evenly. Each sentence goes into the pipeline with
+// a tag block carrying its time, so that what's decoded from it is received when it was
+// originally. Playback starts Offset into the recording and, if Loop is set, starts over
+// at the end, carrying on from the time the last loop finished
+type ReplaySource struct {
+	Path   string
+	Loop   bool
+	Offset time.Duration
+
+	clock *ReplayClock
+}
+
+func NewReplaySource(path string, loop bool, offset time.Duration, clock *ReplayClock) *ReplaySource {
+	return &ReplaySource{Path: path, Loop: loop, Offset: offset, clock: clock}
+}
+
+func (s *ReplaySource) Run(ctx context.Context, sentences chan<- string, connected func()) error {
+	recording, err := openRecording(s.Path)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Replaying AIS file %s at %gx", s.Path, s.clock.speed)
+	connected()
+
+	// added to the recording's times, so that each loop carries on from the last
+	var shift time.Duration
+	for {
+		first, last := s.replay(ctx, recording, sentences, shift)
+		_ = recording.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if s.Loop == false || first.IsZero() {
+			break
+		}
+
+		logger.Infof("Replaying AIS file %s again", s.Path)
+		shift += last.Sub(first) + replayLoopGap
+		if recording, err = openRecording(s.Path); err != nil {
+			logger.WithError(err).Warnf("could not reopen AIS file %s", s.Path)
+			return nil
+		}
+	}
+
+	// the replay is over, but returning would look like a broken connection
+	logger.Infof("Finished replaying AIS file %s", s.Path)
+	<-ctx.Done()
+	return nil
+}
+
+// replay plays the recording through once, returning the recording's times of the first
+// and last sentences it sent
+func (s *ReplaySource) replay(ctx context.Context, recording io.Reader, sentences chan<- string, shift time.Duration) (first, last time.Time) {
+	var start, prev time.Time // the recording's first time, and its latest so far
+	var untimed []string
+
+	// send plays a sentence at its time in the recording, if it's past the offset.
+	// Returns false once ctx is cancelled
+	send := func(line string, t time.Time) bool {
+		if start.IsZero() {
+			start = t
+		}
+		if t.Before(start.Add(s.Offset)) {
+			return true
+		}
+		if first.IsZero() {
+			first = t
+		}
+		last = t
+
+		at := t.Add(shift)
+		wait := at.Sub(s.clock.Now())
+		if s.clock.running() == false || wait > replayMaxWait || wait < -replayMaxWait {
+			s.clock.set(at)
+		} else if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(time.Duration(float64(wait) / s.clock.speed)):
+			}
+		}
+
+		select {
+		case sentences <- withTagTime(line, at):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// flush sends the untimed sentences spread out evenly between prev and next, or at
+	// replaySpacing after prev if next isn't known. Until the recording's first time is
+	// known they're held, unless there isn't one, and they're played from the clock's
+	// time when the recording runs out
+	flush := func(next time.Time) bool {
+		if prev.IsZero() {
+			prev = next
+			if prev.IsZero() {
+				prev = s.clock.Now()
+			}
+		}
+
+		spacing := replaySpacing
+		if next.IsZero() == false {
+			spacing = next.Sub(prev) / time.Duration(len(untimed)+1)
+		}
+		for _, line := range untimed {
+			prev = prev.Add(spacing)
+			if send(line, prev) == false {
+				return false
+			}
+		}
+		untimed = untimed[:0]
+		return true
+	}
+
+	scanner := bufio.NewScanner(recording)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		t := recordedTime(line)
+		if t.IsZero() || t.Before(prev) {
+			untimed = append(untimed, line)
+			if prev.IsZero() == false && len(untimed) >= replayMaxUntimed && flush(time.Time{}) == false {
+				return
+			}
+			continue
+		}
+
+		if flush(t) == false || send(line, t) == false {
+			return
+		}
+		prev = t
+	}
+
+	if err := scanner.Err(); err != nil {
+		logger.WithError(err).Warnf("while replaying AIS file %s", s.Path)
+	}
+	flush(time.Time{})
+	return
+}
+
+// primeClock sets the clock to where playback will start, the recording's first time
+// plus the offset, so that everything that happens before the first sentence is sent --
+// restoring a snapshot, say -- happens at the replay's time. The clock is left alone if
+// the recording can't be read or has no times
+func (s *ReplaySource) primeClock() {
+	recording, err := openRecording(s.Path)
+	if err != nil {
+		return
+	}
+	defer recording.Close()
+
+	scanner := bufio.NewScanner(recording)
+	for scanner.Scan() {
+		if t := recordedTime(strings.TrimSpace(scanner.Text())); t.IsZero() == false {
+			s.clock.set(t.Add(s.Offset))
+			return
+		}
+	}
+}
+
+// closes the file underneath a reader that decompresses or buffers it
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// openRecording opens the file at path, decompressing it if it's gzipped
+func openRecording(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReader(file)
+	if magic, err := buffered.Peek(2); err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return readCloser{buffered, file}, nil
+	}
+
+	unzipped, err := gzip.NewReader(buffered)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return readCloser{unzipped, file}, nil
+}
+
+// recordedTime returns the time a line was recorded: the time in its tag block, or the
+// time reported by a base station. Returns the zero time for any other line
+func recordedTime(line string) time.Time {
+	tag, sentence, _ := ParseTagBlock(line)
+	if tag != nil && tag.Time.IsZero() == false {
+		return tag.Time
+	}
+
+	// a single sentence base station report: !AIVDM,1,1,,A,4...,0*hh
+	fields := strings.Split(sentence, ",")
+	if len(fields) < 7 || fields[1] != "1" || strings.HasPrefix(fields[5], "4") == false {
+		return time.Time{}
+	}
+	report, err := aislib.DecodeBaseStationReport(fields[5])
+	if err != nil || report.Time.Year() < 2000 {
+		return time.Time{}
+	}
+	return report.Time
+}
+
+// withTagTime gives a line a tag block with t as its time, replacing the time in the
+// tag block it has, if any
+func withTagTime(line string, t time.Time) string {
+	fields := []string{fmt.Sprintf("c:%d", t.UnixNano()/int64(time.Millisecond))}
+
+	sentence := line
+	if strings.HasPrefix(line, `\`) {
+		if end := strings.Index(line[1:], `\`); end >= 0 {
+			block := line[1 : end+1]
+			if star := strings.LastIndex(block, "*"); star >= 0 {
+				block = block[:star]
+			}
+			for _, field := range strings.Split(block, ",") {
+				if field != "" && strings.HasPrefix(field, "c:") == false {
+					fields = append(fields, field)
+				}
+			}
+			sentence = line[end+2:]
+		}
+	}
+
+	block := strings.Join(fields, ",")
+	var sum byte
+	for i := 0; i < len(block); i++ {
+		sum ^= block[i]
+	}
+	return fmt.Sprintf(`\%s*%02X\%s`, block, sum, sentence)
+}
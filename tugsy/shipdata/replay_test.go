@@ -0,0 +1,114 @@
+package shipdata
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeRecording(t *testing.T, lines ...string) string {
+	dir, err := ioutil.TempDir("", "tugsy")
+	assert.NoError(t, err)
+
+	path := filepath.Join(dir, "recording.nmea.gz")
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	zw := gzip.NewWriter(f)
+	for _, line := range lines {
+		_, _ = zw.Write([]byte(line + "\r\n"))
+	}
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, f.Close())
+	return path
+}
+
+func replayed(t *testing.T, source *ReplaySource, n int) []*TagBlock {
+	sentences := make(chan string, n)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- source.Run(ctx, sentences, func() {}) }()
+
+	var tags []*TagBlock
+	for i := 0; i < n; i++ {
+		select {
+		case line := <-sentences:
+			tag, _, err := ParseTagBlock(line)
+			assert.NoError(t, err)
+			tags = append(tags, tag)
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of %d sentences replayed", i, n)
+		}
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+	return tags
+}
+
+func TestReplaySpreadsUntimedSentences(t *testing.T) {
+	path := writeRecording(t,
+		withTagTime("!AIVDM,1,1,,A,first,0*00", time.Unix(1500000000, 0)),
+		"!AIVDM,1,1,,A,untimed,0*00",
+		withTagTime("!AIVDM,1,1,,A,last,0*00", time.Unix(1500000002, 0)),
+	)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	clock := NewReplayClock(100)
+	tags := replayed(t, NewReplaySource(path, false, 0, clock), 3)
+
+	assert.Equal(t, time.Unix(1500000000, 0), tags[0].Time)
+	assert.Equal(t, time.Unix(1500000001, 0), tags[1].Time)
+	assert.Equal(t, time.Unix(1500000002, 0), tags[2].Time)
+	assert.False(t, clock.Now().Before(time.Unix(1500000002, 0)))
+}
+
+func TestReplayOffsetAndLoop(t *testing.T) {
+	path := writeRecording(t,
+		withTagTime("!AIVDM,1,1,,A,first,0*00", time.Unix(1500000000, 0)),
+		withTagTime("!AIVDM,1,1,,A,second,0*00", time.Unix(1500000030, 0)),
+	)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	// the offset skips the first sentence every time round, and each loop carries on
+	// replayLoopGap after the last
+	tags := replayed(t, NewReplaySource(path, true, 10*time.Second, NewReplayClock(100)), 2)
+	assert.Equal(t, time.Unix(1500000030, 0), tags[0].Time)
+	assert.Equal(t, time.Unix(1500000030, 0).Add(replayLoopGap), tags[1].Time)
+}
+
+func TestWithTagTime(t *testing.T) {
+	line := withTagTime(`\s:station,c:1500000000*00\!AIVDM,1,1,,A,payload,0*00`, time.Unix(1600000000, 0))
+	tag, sentence, err := ParseTagBlock(line)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1600000000, 0), tag.Time)
+	assert.Equal(t, "station", tag.Source)
+	assert.Equal(t, "!AIVDM,1,1,,A,payload,0*00", sentence)
+}
+
+func TestReplayHoldsUntimedSentencesUntilTheFirstTime(t *testing.T) {
+	path := writeRecording(t,
+		"!AIVDM,1,1,,A,untimed,0*00",
+		withTagTime("!AIVDM,1,1,,A,first,0*00", time.Unix(1500000000, 0)),
+		withTagTime("!AIVDM,1,1,,A,second,0*00", time.Unix(1500000001, 0)),
+	)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	aisData := NewAISData()
+	router := &RemoteAISServer{Type: ReplaySourceType, Path: path, Speed: 100, aisData: aisData}
+	source, err := newAISSource(router)
+	assert.NoError(t, err)
+
+	// the clock is at the start of the recording before anything is played
+	assert.Equal(t, time.Unix(1500000000, 0).Unix(), aisData.Now().Unix())
+
+	tags := replayed(t, source.(*ReplaySource), 3)
+	assert.Equal(t, time.Unix(1500000000, 0), tags[0].Time)
+	assert.Equal(t, time.Unix(1500000000, 0), tags[1].Time)
+	assert.Equal(t, time.Unix(1500000001, 0), tags[2].Time)
+}
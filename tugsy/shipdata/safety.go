@@ -0,0 +1,218 @@
+package shipdata
+
+import (
+	"sort"
+	"time"
+
+	"github.com/andmarios/aislib"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAlertRetentionDur       = 30 * time.Minute
+	defaultSARAircraftRetentionDur = 10 * time.Minute
+
+	// the source of alerts raised for zone events, rather than received
+	zoneAlertSource = "zones"
+)
+
+// SafetyMessage is a safety related text message, either addressed to a single station
+// (type 12) or broadcast to all of them (type 14)
+type SafetyMessage struct {
+	Type     uint8
+	Repeat   uint8
+	MMSI     uint32
+	DestMMSI uint32 // zero for broadcasts
+	Text     string
+}
+
+func DecodeSafetyMessage(payload string) (SafetyMessage, error) {
+	p := unarmor(payload)
+	if err := p.checkLength(uint8(p.uint(0, 6)), 40); err != nil {
+		return SafetyMessage{}, err
+	}
+
+	message := SafetyMessage{
+		Type:   uint8(p.uint(0, 6)),
+		Repeat: uint8(p.uint(6, 2)),
+		MMSI:   p.uint(8, 30),
+	}
+
+	textStart := 40
+	if message.Type == 12 {
+		if err := p.checkLength(12, 72); err != nil {
+			return SafetyMessage{}, err
+		}
+		message.DestMMSI = p.uint(40, 30)
+		textStart = 72
+	}
+
+	message.Text = p.text(textStart, len(p)-textStart)
+	return message, nil
+}
+
+// SARAircraftReport is message type 9, the position of an aircraft taking part in a
+// search and rescue operation
+type SARAircraftReport struct {
+	aislib.PositionReport
+	Altitude uint16 // meters, 4095 is "not available"
+	DTE      bool
+	Assigned bool
+}
+
+func DecodeSARAircraftReport(payload string) (SARAircraftReport, error) {
+	p := unarmor(payload)
+	if err := p.checkLength(9, 168); err != nil {
+		return SARAircraftReport{}, err
+	}
+
+	return SARAircraftReport{
+		PositionReport: aislib.PositionReport{
+			Type:     uint8(p.uint(0, 6)),
+			Repeat:   uint8(p.uint(6, 2)),
+			MMSI:     p.uint(8, 30),
+			Speed:    float32(p.uint(50, 10)), // whole knots, unlike the ships' reports
+			Accuracy: p.bool(60),
+			Lon:      float64(p.int(61, 28)) / 600000,
+			Lat:      float64(p.int(89, 27)) / 600000,
+			Course:   float32(p.uint(116, 12)) / 10,
+			Heading:  511, // not available
+			Second:   uint8(p.uint(128, 6)),
+			RAIM:     p.bool(147),
+		},
+		Altitude: uint16(p.uint(38, 12)),
+		DTE:      p.bool(142),
+		Assigned: p.bool(146),
+	}, nil
+}
+
+type SourcedSARAircraftReport struct {
+	SARAircraftReport
+	SourceAndTime
+}
+
+func (sarPos *SourcedSARAircraftReport) GetPositionReport() *aislib.PositionReport {
+	return &sarPos.PositionReport
+}
+
+// An Alert is a safety message in the alert log. Stations usually repeat their messages,
+// so a repeat of an alert refreshes it rather than adding another. Alerts raised by
+// tugsy itself, for zone events, have a message of Type 0
+type Alert struct {
+	ID        uint64
+	Message   SafetyMessage
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Source    string
+	Dismissed bool
+}
+
+// Expired returns true if the alert hasn't been repeated within the retention period
+func (a *Alert) Expired(now time.Time, retention time.Duration) bool {
+	return a.LastSeen.Add(retention).Before(now)
+}
+
+// AddSafetyMessage adds the message to the alert log, or refreshes the alert it repeats
+func (aisData *AISData) AddSafetyMessage(message SafetyMessage, st SourceAndTime) {
+	aisData.addAlert(message, st.Source(), st.ReceivedTime())
+}
+
+func (aisData *AISData) addAlert(message SafetyMessage, source string, at time.Time) {
+	aisData.Lock()
+	defer aisData.Unlock()
+
+	for _, alert := range aisData.alerts {
+		if alert.Message.MMSI == message.MMSI && alert.Message.Text == message.Text {
+			alert.LastSeen = at
+			return
+		}
+	}
+
+	aisData.lastAlertID++
+	aisData.alerts = append(aisData.alerts, &Alert{
+		ID:        aisData.lastAlertID,
+		Message:   message,
+		FirstSeen: at,
+		LastSeen:  at,
+		Source:    source,
+	})
+}
+
+// Alerts returns copies of the alerts that are neither dismissed nor expired, newest
+// first
+func (aisData *AISData) Alerts() []Alert {
+	aisData.Lock()
+	defer aisData.Unlock()
+
+	now := aisData.Now()
+	alerts := make([]Alert, 0, len(aisData.alerts))
+	for _, alert := range aisData.alerts {
+		if alert.Dismissed || alert.Expired(now, aisData.AlertRetentionDur) {
+			continue
+		}
+		alerts = append(alerts, *alert)
+	}
+
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].FirstSeen.After(alerts[j].FirstSeen) })
+	return alerts
+}
+
+// DismissAlert hides the alert with the given ID. It stays dismissed if it's repeated
+func (aisData *AISData) DismissAlert(id uint64) {
+	aisData.Lock()
+	defer aisData.Unlock()
+
+	for _, alert := range aisData.alerts {
+		if alert.ID == id {
+			alert.Dismissed = true
+			return
+		}
+	}
+}
+
+func (aisData *AISData) pruneAlerts(now time.Time) {
+	aisData.Lock()
+	defer aisData.Unlock()
+
+	alerts := aisData.alerts[:0]
+	for _, alert := range aisData.alerts {
+		if alert.Expired(now, aisData.AlertRetentionDur) == false {
+			alerts = append(alerts, alert)
+		}
+	}
+	aisData.alerts = alerts
+}
+
+func (aisData *AISData) UpdateSARAircraft(report *SourcedSARAircraftReport) {
+	aisData.Lock()
+	defer aisData.Unlock()
+	aisData.mmsiSARAircraft[report.MMSI] = report
+}
+
+// SARAircraft returns a copy of the slice of the latest reports from every SAR aircraft
+func (aisData *AISData) SARAircraft() []*SourcedSARAircraftReport {
+	aisData.Lock()
+	defer aisData.Unlock()
+
+	aircraft := make([]*SourcedSARAircraftReport, 0, len(aisData.mmsiSARAircraft))
+	for _, a := range aisData.mmsiSARAircraft {
+		aircraft = append(aircraft, a)
+	}
+
+	return aircraft
+}
+
+// pruneSARAircraft forgets aircraft that haven't reported since the given time. They
+// move quickly and leave the area just as quickly, so they're kept for much less time
+// than ships
+func (aisData *AISData) pruneSARAircraft(since time.Time) {
+	aisData.Lock()
+	defer aisData.Unlock()
+
+	for mmsi, a := range aisData.mmsiSARAircraft {
+		if a.ReceivedTime().Before(since) {
+			logger.Infof("a SAR aircraft has not been heard from in a while. Removing MMSI %v", mmsi)
+			delete(aisData.mmsiSARAircraft, mmsi)
+		}
+	}
+}
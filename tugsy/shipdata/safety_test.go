@@ -0,0 +1,79 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeSafetyMessage(t *testing.T) {
+	broadcast, err := DecodeSafetyMessage(">5?Per18=HB1U:1@E=B0m<L")
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(14), broadcast.Type)
+	assert.Equal(t, uint32(351809000), broadcast.MMSI)
+	assert.Equal(t, uint32(0), broadcast.DestMMSI)
+	assert.Equal(t, "RCVD YR TEST MSG", broadcast.Text)
+
+	bits := make(payloadBits, 72, 72+6*5)
+	bits.set(0, 6, 12)
+	bits.set(8, 30, 3669713)
+	bits.set(40, 30, 366998410)
+	for _, c := range "MAYDAY" {
+		bits = append(bits, make(payloadBits, 6)...)
+		bits.set(len(bits)-6, 6, int32(c)&0x3f)
+	}
+
+	addressed, err := DecodeSafetyMessage(armor(bits))
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(12), addressed.Type)
+	assert.Equal(t, uint32(366998410), addressed.DestMMSI)
+	assert.Equal(t, "MAYDAY", addressed.Text)
+}
+
+func TestDecodeSARAircraftReport(t *testing.T) {
+	bits := make(payloadBits, 168)
+	bits.set(0, 6, 9)
+	bits.set(8, 30, 111232506)
+	bits.set(38, 12, 300)
+	bits.set(50, 10, 120)
+	bits.set(61, 28, -71400*600)
+	bits.set(89, 27, 41500*600)
+	bits.set(116, 12, 2705)
+
+	report, err := DecodeSARAircraftReport(armor(bits))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(111232506), report.MMSI)
+	assert.Equal(t, uint16(300), report.Altitude)
+	assert.Equal(t, float32(120), report.Speed)
+	assert.InDelta(t, -71.4, report.Lon, 0.000001)
+	assert.InDelta(t, 41.5, report.Lat, 0.000001)
+	assert.InDelta(t, 270.5, report.Course, 0.01)
+}
+
+func TestAlertLog(t *testing.T) {
+	aisData := NewAISData()
+	now := time.Now()
+
+	first := SafetyMessage{Type: 14, MMSI: 3669713, Text: "SECURITE"}
+	second := SafetyMessage{Type: 14, MMSI: 3669713, Text: "NAV WARNING"}
+	aisData.AddSafetyMessage(first, SourceAndTime{sourceName: "radio", receivedTime: now.Add(-time.Minute)})
+	aisData.AddSafetyMessage(second, SourceAndTime{sourceName: "radio", receivedTime: now})
+
+	// a repeat refreshes the alert instead of adding another
+	aisData.AddSafetyMessage(first, SourceAndTime{sourceName: "radio", receivedTime: now})
+
+	alerts := aisData.Alerts()
+	assert.Equal(t, 2, len(alerts))
+	assert.Equal(t, "NAV WARNING", alerts[0].Message.Text)
+	assert.Equal(t, now, alerts[1].LastSeen)
+
+	aisData.DismissAlert(alerts[0].ID)
+	alerts = aisData.Alerts()
+	assert.Equal(t, 1, len(alerts))
+	assert.Equal(t, "SECURITE", alerts[0].Message.Text)
+
+	// expired alerts are hidden, then pruned
+	aisData.pruneAlerts(now.Add(aisData.AlertRetentionDur + time.Second))
+	assert.Equal(t, 0, len(aisData.alerts))
+}
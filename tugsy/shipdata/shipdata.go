@@ -1,10 +1,13 @@
 package shipdata
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/andmarios/aislib"
+	"github.com/joemadeus/tugsy/tugsy/weatherdata"
 	logger "github.com/sirupsen/logrus"
 )
 
@@ -27,8 +30,13 @@ type ShipHistory struct {
 	MMSI uint32
 
 	// append new positions to the end
-	positions  []Positionable
-	voyagedata *SourcedStaticVoyageData
+	positions   []Positionable
+	voyagedata  *SourcedStaticVoyageData
+	compression TrackCompression
+
+	// positions held back by the outlier filter, and the number it has discarded
+	quarantine []Positionable
+	outliers   int
 
 	// a cache of positions ready to go to the UI, emptied by calls
 	// that modify the positions slice, populated by calls to get
@@ -37,7 +45,7 @@ type ShipHistory struct {
 }
 
 func NewShipHistory(mmsi uint32) *ShipHistory {
-	return &ShipHistory{MMSI: mmsi, positions: make([]Positionable, 0)}
+	return &ShipHistory{MMSI: mmsi, positions: make([]Positionable, 0), compression: DefaultTrackCompression()}
 }
 
 // Positions returns a copy of the slice of Positionables currently associated with
@@ -60,18 +68,52 @@ func (h *ShipHistory) VoyageData() *SourcedStaticVoyageData {
 	return h.voyagedata
 }
 
-func (h *ShipHistory) addPosition(report Positionable) {
+// addPosition adds report to the track, returning the positions that were actually
+// added: none if it's held back by the outlier filter, or more than one if it confirms
+// positions that were
+func (h *ShipHistory) addPosition(report Positionable) []Positionable {
 	h.Lock()
 	defer h.Unlock()
-	h.positions = append(h.positions, report)
-	h.posCache = nil
+
+	added := h.filterOutlier(report)
+	for _, position := range added {
+		h.positions = append(h.positions, position)
+		h.positions = h.compression.compress(h.positions)
+		h.posCache = nil
+	}
+	return added
 }
 
-func (h *ShipHistory) setVoyageData(d *SourcedStaticVoyageData) {
+// setVoyageData replaces the voyage data, returning true if that changed anything
+func (h *ShipHistory) setVoyageData(d *SourcedStaticVoyageData) bool {
 	h.Lock()
 	defer h.Unlock()
 
+	changed := h.voyagedata == nil || d.StaticVoyageData != h.voyagedata.StaticVoyageData
 	h.voyagedata = d
+	return changed
+}
+
+// mergeVoyageData applies update to a copy of the current voyage data (or to new voyage
+// data, if there's none yet) and replaces the current voyage data with it. This is how
+// partial static reports (types 19 & 24) build up a whole picture of the vessel. Copying
+// means a caller holding on to the old voyage data never sees it change. Returns true if
+// the update changed anything
+func (h *ShipHistory) mergeVoyageData(st SourceAndTime, update func(*aislib.StaticVoyageData)) bool {
+	h.Lock()
+	defer h.Unlock()
+
+	merged := &SourcedStaticVoyageData{SourceAndTime: st}
+	if h.voyagedata != nil {
+		merged.StaticVoyageData = h.voyagedata.StaticVoyageData
+	} else {
+		merged.MMSI = h.MMSI
+	}
+
+	update(&merged.StaticVoyageData)
+	changed := h.voyagedata == nil || merged.StaticVoyageData != h.voyagedata.StaticVoyageData
+	h.voyagedata = merged
+	return changed
 }
 
 func (h *ShipHistory) prune(since time.Time) int {
@@ -84,10 +126,11 @@ func (h *ShipHistory) prune(since time.Time) int {
 		return len(h.positions)
 	}
 
-	var a int
-	var position Positionable
-	for a, position = range h.positions {
+	// if none are after 'since', all of them go
+	a := len(h.positions)
+	for i, position := range h.positions {
 		if position.ReceivedTime().After(since) {
+			a = i
 			break
 		}
 	}
@@ -103,9 +146,50 @@ type AISData struct {
 	mmsiHistories    map[uint32]*ShipHistory
 	mmsiBaseStations map[uint32]*SourcedBaseStationReport
 	mmsiBinaryData   map[uint32]*SourcedBinaryBroadcast
+	mmsiAtoNs        map[uint32]*SourcedAidToNavigationReport
+	mmsiSARAircraft  map[uint32]*SourcedSARAircraftReport
+	spatial          *SpatialIndex // over the ships' latest positions
+	alerts           []*Alert
+	lastAlertID      uint64
+
+	// if set, readings from weather stations broadcasting met-hydro data are published here
+	Weather *weatherdata.WeatherData
+
+	// if set, every position and voyage data update is recorded here
+	History *HistoryStore
+
+	// if set, ships' static data is registered here, and new ShipHistories start with the
+	// static data registered for them
+	Registry *VesselRegistry
+
+	// if set, every position added to a ship's track is evaluated against these zones
+	Geofences *Geofences
+
+	// if set, arrivals and departures are inferred from every position added to a ship's
+	// track, using the Geofences' berth zones
+	PortCalls *PortCallDetector
+
+	// if set, tugs escorting large vessels are detected from every position added to a
+	// ship's track
+	TugJobs *TugJobDetector
+
+	// if set, the closest point of approach of every pair of moving vessels is computed
+	// as their positions arrive, and close quarters situations are added to the alert log
+	CloseQuarters *CloseQuarters
+
+	// if set, retention is measured against this clock rather than the wall clock. A
+	// replay source sets it to the replay's clock
+	Clock Clock
 
 	PositionRetentionDur    time.Duration
 	PositionCullingInterval time.Duration
+	TrackCompression        TrackCompression
+	AlertRetentionDur       time.Duration
+	SARAircraftRetentionDur time.Duration
+
+	// separate from the main lock, so events can be published while holding neither
+	subscribersLock sync.Mutex
+	subscribers     []changeSubscriber
 }
 
 func NewAISData() *AISData {
@@ -113,33 +197,187 @@ func NewAISData() *AISData {
 		mmsiHistories:    make(map[uint32]*ShipHistory),
 		mmsiBaseStations: make(map[uint32]*SourcedBaseStationReport),
 		mmsiBinaryData:   make(map[uint32]*SourcedBinaryBroadcast),
+		mmsiAtoNs:        make(map[uint32]*SourcedAidToNavigationReport),
+		mmsiSARAircraft:  make(map[uint32]*SourcedSARAircraftReport),
+		spatial:          NewSpatialIndex(defaultGridCellDegrees),
 
 		PositionRetentionDur:    defaultPositionRetentionDur,
 		PositionCullingInterval: defaultPositionCullingInterval,
+		TrackCompression:        DefaultTrackCompression(),
+		AlertRetentionDur:       defaultAlertRetentionDur,
+		SARAircraftRetentionDur: defaultSARAircraftRetentionDur,
 	}
 }
 
 func (aisData *AISData) AddPosition(report Positionable) {
-	history := aisData.getOrCreateShipHistory(report.GetPositionReport().MMSI)
-	history.addPosition(report)
+	history := aisData.getOrCreateShipHistory(report.GetPositionReport().MMSI, report.ReceivedTime())
+	added := history.addPosition(report)
+
+	// only what the outlier filter lets through, so the stored tracks are as clean as the
+	// ones in memory
+	if aisData.History != nil {
+		for _, position := range added {
+			aisData.History.RecordPosition(position)
+		}
+	}
+
+	if aisData.Registry != nil {
+		aisData.Registry.seen(history.MMSI, report.ReceivedTime())
+	}
+
+	if len(added) > 0 {
+		latest := added[len(added)-1].GetPositionReport()
+		aisData.spatial.update(history.MMSI, latest.Lat, latest.Lon)
+	}
+
+	for _, position := range added {
+		aisData.publish(ChangeEvent{Type: PositionAdded, MMSI: history.MMSI, At: position.ReceivedTime(), Position: position})
+
+		var berths []*Zone
+		if aisData.Geofences != nil {
+			for _, event := range aisData.Geofences.evaluate(position) {
+				if event.Zone.Alert {
+					aisData.addAlert(SafetyMessage{MMSI: event.MMSI, Text: fmt.Sprintf("%s %s", event.Type, event.Zone.Name)}, zoneAlertSource, event.At)
+				}
+			}
+			for _, zone := range aisData.Geofences.Inside(history.MMSI) {
+				if zone.Berth {
+					berths = append(berths, zone)
+				}
+			}
+		}
+
+		if aisData.PortCalls != nil {
+			aisData.PortCalls.evaluate(position, history.VoyageData(), berths)
+		}
+
+		report := position.GetPositionReport()
+		if aisData.TugJobs != nil {
+			nearby := aisData.shipsNear(report.Lat, report.Lon, aisData.TugJobs.searchMeters(position))
+			aisData.TugJobs.evaluate(position, history, nearby)
+		}
+
+		if aisData.CloseQuarters != nil {
+			var skip func(a, b uint32) bool
+			if aisData.TugJobs != nil {
+				skip = aisData.TugJobs.escorting // tugs are supposed to be close
+			}
+			nearby := aisData.shipsNear(report.Lat, report.Lon, aisData.CloseQuarters.searchMeters(position))
+			for _, event := range aisData.CloseQuarters.evaluate(position, nearby, skip) {
+				if event.Type == CloseQuartersStarted {
+					e := event.Encounter
+					text := fmt.Sprintf("close quarters with %d, CPA %.0fm in %s", e.B, e.Approach.CPAMeters, e.Approach.TCPA.Round(time.Second))
+					aisData.addAlert(SafetyMessage{MMSI: e.A, Text: text}, closeQuartersAlertSource, e.Since)
+				}
+			}
+		}
+	}
 }
 
-func (aisData *AISData) getOrCreateShipHistory(mmsi uint32) *ShipHistory {
+// getOrCreateShipHistory returns the vessel's ShipHistory, creating it and publishing a
+// VesselAdded event if it's new. at is when the report that mentions it was received
+func (aisData *AISData) getOrCreateShipHistory(mmsi uint32, at time.Time) *ShipHistory {
 	aisData.Lock()
-	defer aisData.Unlock()
-
 	history, ok := aisData.mmsiHistories[mmsi]
 	if ok == false {
 		history = NewShipHistory(mmsi)
+		history.compression = aisData.TrackCompression
+		aisData.seedVoyageData(history)
 		aisData.mmsiHistories[mmsi] = history
 	}
+	aisData.Unlock()
 
+	if ok == false {
+		aisData.publish(ChangeEvent{Type: VesselAdded, MMSI: mmsi, At: at})
+	}
 	return history
 }
 
+// seedVoyageData gives a new ShipHistory the static data registered for its ship, if any
+func (aisData *AISData) seedVoyageData(history *ShipHistory) {
+	if aisData.Registry == nil {
+		return
+	}
+	if v, ok := aisData.Registry.Vessel(history.MMSI); ok {
+		history.setVoyageData(v.voyageData())
+	}
+}
+
 func (aisData *AISData) UpdateStaticVoyageData(data *SourcedStaticVoyageData) {
-	history := aisData.getOrCreateShipHistory(data.MMSI)
-	history.setVoyageData(data)
+	history := aisData.getOrCreateShipHistory(data.MMSI, data.ReceivedTime())
+	changed := history.setVoyageData(data)
+	if changed {
+		aisData.recordVoyageData(history)
+		aisData.voyageDataChanged(history)
+	}
+}
+
+// UpdateStaticDataReport merges one part of a type 24 static data report into the
+// vessel's voyage data
+func (aisData *AISData) UpdateStaticDataReport(report *SourcedStaticDataReport) {
+	history := aisData.getOrCreateShipHistory(report.MMSI, report.ReceivedTime())
+	changed := history.mergeVoyageData(report.SourceAndTime, func(d *aislib.StaticVoyageData) {
+		if report.PartNumber == 0 {
+			d.VesselName = report.VesselName
+			return
+		}
+
+		d.ShipType = report.ShipType
+		d.Callsign = report.Callsign
+		if report.MothershipMMSI == 0 {
+			d.ToBow = report.ToBow
+			d.ToStern = report.ToStern
+			d.ToPort = report.ToPort
+			d.ToStarboard = report.ToStarboard
+		}
+	})
+	if changed {
+		aisData.recordVoyageData(history)
+		aisData.voyageDataChanged(history)
+	}
+}
+
+// AddExtendedClassBPosition adds the position from a type 19 report and merges its
+// static data into the vessel's voyage data
+func (aisData *AISData) AddExtendedClassBPosition(report *SourcedExtendedClassBPositionReport) {
+	aisData.AddPosition(report)
+
+	history := aisData.getOrCreateShipHistory(report.MMSI, report.ReceivedTime())
+	changed := history.mergeVoyageData(report.SourceAndTime, func(d *aislib.StaticVoyageData) {
+		d.VesselName = report.VesselName
+		d.ShipType = report.ShipType
+		d.ToBow = report.ToBow
+		d.ToStern = report.ToStern
+		d.ToPort = report.ToPort
+		d.ToStarboard = report.ToStarboard
+		d.EPFD = report.EPFD
+		d.DTE = report.DTE
+	})
+	if changed {
+		aisData.recordVoyageData(history)
+		aisData.voyageDataChanged(history)
+	}
+}
+
+// voyageDataChanged registers the ship's voyage data, as it stands after an update, and
+// publishes a StaticDataChanged event with it
+func (aisData *AISData) voyageDataChanged(history *ShipHistory) {
+	if data := history.VoyageData(); data != nil {
+		if aisData.Registry != nil {
+			aisData.Registry.update(data)
+		}
+		aisData.publish(ChangeEvent{Type: StaticDataChanged, MMSI: history.MMSI, At: data.ReceivedTime(), VoyageData: data})
+	}
+}
+
+// recordVoyageData records the ship's voyage data, as it stands after an update, in History
+func (aisData *AISData) recordVoyageData(history *ShipHistory) {
+	if aisData.History == nil {
+		return
+	}
+	if data := history.VoyageData(); data != nil {
+		aisData.History.RecordVoyageData(data)
+	}
 }
 
 func (aisData *AISData) UpdateBaseStationReport(report *SourcedBaseStationReport) {
@@ -154,15 +392,35 @@ func (aisData *AISData) UpdateBinaryBroadcast(report *SourcedBinaryBroadcast) {
 	aisData.mmsiBinaryData[report.MMSI] = report
 }
 
-// Forever periodically prunes positions from all the known ship histories.
-func (aisData *AISData) PrunePositions() {
-	tick := time.Tick(aisData.PositionCullingInterval)
+// A Clock tells the time that AIS data is measured against
+type Clock interface {
+	Now() time.Time
+}
+
+// Now returns the time according to the Clock, or the wall clock if there isn't one
+func (aisData *AISData) Now() time.Time {
+	if aisData.Clock == nil {
+		return time.Now()
+	}
+	return aisData.Clock.Now()
+}
+
+// PrunePositions periodically prunes positions from all the known ship histories until
+// ctx is cancelled
+func (aisData *AISData) PrunePositions(ctx context.Context) {
+	ticker := time.NewTicker(aisData.PositionCullingInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
-		case <-tick:
+		case <-ctx.Done():
+			logger.Info("position culling loop exiting")
+			return
+
+		case <-ticker.C:
 			logger.Debug("culling positions")
-			since := time.Now().Add(-aisData.PositionRetentionDur)
+			now := aisData.Now()
+			since := now.Add(-aisData.PositionRetentionDur)
 
 			// make a copy of the histories refs so we don't have to maintain the lock on
 			// aisData. doing so means potentially examining only a subset of all the shipdata,
@@ -170,15 +428,44 @@ func (aisData *AISData) PrunePositions() {
 			// we miss next time
 			for _, sh := range aisData.ShipHistories() {
 				if sh.prune(since) == 0 {
+					pruned := false
 					aisData.Lock()
 					// retest for positions within lock
 					if len(sh.positions) == 0 {
+						pruned = true
 						logger.Infof("a ship has not been heard from in a while. Removing MMSI %v", sh.MMSI)
 						delete(aisData.mmsiHistories, sh.MMSI)
+						aisData.spatial.remove(sh.MMSI)
+						if aisData.Geofences != nil {
+							aisData.Geofences.forget(sh.MMSI)
+						}
+						if aisData.PortCalls != nil {
+							aisData.PortCalls.forget(sh.MMSI)
+						}
+						if aisData.TugJobs != nil {
+							aisData.TugJobs.forget(sh.MMSI)
+						}
 					}
 					aisData.Unlock()
+
+					if pruned {
+						aisData.publish(ChangeEvent{Type: VesselPruned, MMSI: sh.MMSI, At: now})
+					}
 				}
 			}
+
+			aisData.pruneAidsToNavigation(since)
+			aisData.pruneSARAircraft(now.Add(-aisData.SARAircraftRetentionDur))
+			aisData.pruneAlerts(now)
+			if aisData.TugJobs != nil {
+				aisData.TugJobs.expire(now)
+			}
+			if aisData.CloseQuarters != nil {
+				aisData.CloseQuarters.expire(now)
+			}
+			if aisData.Weather != nil {
+				aisData.Weather.PruneStations(since)
+			}
 		}
 	}
 }
@@ -198,6 +485,37 @@ func (aisData *AISData) ShipHistories() []*ShipHistory {
 	return shs
 }
 
+// ShipsInBounds returns the ShipHistories whose latest positions are inside box
+func (aisData *AISData) ShipsInBounds(box BoundingBox) []*ShipHistory {
+	return aisData.shipHistoriesOf(aisData.spatial.InBounds(box))
+}
+
+// NearestShips returns the ShipHistories of up to k ships whose latest positions are
+// closest to lat/lon, nearest first
+func (aisData *AISData) NearestShips(lat, lon float64, k int) []*ShipHistory {
+	return aisData.shipHistoriesOf(aisData.spatial.Nearest(lat, lon, k))
+}
+
+// shipsNear returns the ShipHistories whose latest positions are within about meters of
+// lat/lon, give or take the corners of the box bounding the circle
+func (aisData *AISData) shipsNear(lat, lon, meters float64) []*ShipHistory {
+	return aisData.ShipsInBounds(boxAround(lat, lon, meters))
+}
+
+func (aisData *AISData) shipHistoriesOf(mmsis []uint32) []*ShipHistory {
+	aisData.Lock()
+	defer aisData.Unlock()
+
+	shs := make([]*ShipHistory, 0, len(mmsis))
+	for _, mmsi := range mmsis {
+		// the index is updated outside the lock, so it may briefly hold a pruned ship
+		if sh, ok := aisData.mmsiHistories[mmsi]; ok {
+			shs = append(shs, sh)
+		}
+	}
+	return shs
+}
+
 // Returns the ShipHistory/true associated with the given MMSI, or nil/false if it doesn't
 func (aisData *AISData) ShipHistory(mmsi uint32) (*ShipHistory, bool) {
 	aisData.Lock()
@@ -1,6 +1,7 @@
 package shipdata
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -21,6 +22,9 @@ func NewMockPositionReport(receivedTime time.Time, mmsi uint32) *MockPositionRep
 }
 
 func (m *MockPositionReport) GetPositionReport() *aislib.PositionReport {
+	if m.positionReport == nil {
+		return &aislib.PositionReport{}
+	}
 	return m.positionReport
 }
 
@@ -66,6 +70,40 @@ func TestEmptyPrune(t *testing.T) {
 	assert.Equal(t, 0, len(sh.positions))
 }
 
+func TestPruneEverything(t *testing.T) {
+	now := time.Now()
+	sh := NewShipHistory(1)
+	sh.addPosition(&MockPositionReport{receivedTime: now.Add(-20 * time.Second)})
+	sh.addPosition(&MockPositionReport{receivedTime: now.Add(-10 * time.Second)})
+	assert.Equal(t, 0, sh.prune(now))
+	assert.Equal(t, 0, len(sh.positions))
+}
+
+func TestPrunePositionsRemovesStaleShips(t *testing.T) {
+	aisData := NewAISData()
+	aisData.PositionRetentionDur = time.Minute
+	aisData.PositionCullingInterval = 10 * time.Millisecond
+	pruned := aisData.Subscribe(ChangeTypes(VesselPruned))
+
+	aisData.AddPosition(positionAt(41.8, -71.39, time.Now().Add(-time.Hour)))
+	box := BoundingBox{South: 41.7, West: -71.5, North: 41.9, East: -71.3}
+	assert.Equal(t, 1, len(aisData.ShipsInBounds(box)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go aisData.PrunePositions(ctx)
+
+	select {
+	case event := <-pruned:
+		assert.Equal(t, uint32(1), event.MMSI)
+	case <-time.After(time.Second):
+		t.Fatal("the stale ship was never pruned")
+	}
+	assert.Equal(t, 0, len(aisData.ShipsInBounds(box)))
+	_, ok := aisData.ShipHistory(1)
+	assert.False(t, ok)
+}
+
 func TestGetPositionReports(t *testing.T) {
 	now := time.Now()
 	sh := NewShipHistory(1)
@@ -75,3 +113,38 @@ func TestGetPositionReports(t *testing.T) {
 	sh.addPosition(posOne)
 	sh.addPosition(posTwo)
 }
+
+func TestDecodeAttributesSource(t *testing.T) {
+	aisData := NewAISData()
+	router := &RemoteAISServer{SourceName: "radio", aisData: aisData, stats: SourceStats{ByType: make(map[uint8]uint64)}}
+
+	message := aislib.Message{Type: 1, Payload: "15NQuePP00rq9v2GsD?emOwh20Rf"}
+	err := router.decodeMessage(message, SourceAndTime{sourceName: router.SourceName, receivedTime: time.Now()})
+	router.countMessage(message.Type, err)
+	router.countMessage(6, router.decodeMessage(aislib.Message{Type: 6}, SourceAndTime{sourceName: router.SourceName}))
+
+	histories := aisData.ShipHistories()
+	assert.Equal(t, 1, len(histories))
+	assert.Equal(t, "radio", histories[0].Positions()[0].Source())
+
+	stats := router.Stats()
+	assert.Equal(t, uint64(1), stats.Decoded)
+	assert.Equal(t, uint64(1), stats.Unsupported)
+	assert.Equal(t, uint64(1), stats.ByType[1])
+}
+
+func TestPruneExitsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewAISData().PrunePositions(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pruning did not exit after cancellation")
+	}
+}
@@ -0,0 +1,241 @@
+package shipdata
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andmarios/aislib"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	snapshotVersion         = 1
+	defaultSnapshotInterval = 1 * time.Minute
+)
+
+type SnapshotVersionError struct {
+	Version int
+}
+
+func (e SnapshotVersionError) Error() string {
+	return fmt.Sprintf("snapshot version %d is not supported", e.Version)
+}
+
+// A snapshot is what's written to disk: the ship histories, with each position's
+// concrete type recorded alongside it so that it can be restored as the same type
+type snapshot struct {
+	Version int
+	Written time.Time
+	Ships   []snapshotShip
+}
+
+type snapshotShip struct {
+	MMSI       uint32
+	Positions  []snapshotPosition
+	VoyageData *snapshotVoyageData `json:",omitempty"`
+}
+
+type snapshotSource struct {
+	Source   string
+	Station  string `json:",omitempty"`
+	Received time.Time
+}
+
+func newSnapshotSource(st *SourceAndTime) snapshotSource {
+	return snapshotSource{Source: st.sourceName, Station: st.station, Received: st.receivedTime}
+}
+
+func (s snapshotSource) sourceAndTime() SourceAndTime {
+	return SourceAndTime{sourceName: s.Source, station: s.Station, receivedTime: s.Received}
+}
+
+// exactly one of the reports is set
+type snapshotPosition struct {
+	snapshotSource
+	ClassA         *aislib.ClassAPositionReport  `json:",omitempty"`
+	ClassB         *aislib.ClassBPositionReport  `json:",omitempty"`
+	ExtendedClassB *ExtendedClassBPositionReport `json:",omitempty"`
+	LongRange      *LongRangePositionReport      `json:",omitempty"`
+}
+
+type snapshotVoyageData struct {
+	snapshotSource
+	aislib.StaticVoyageData
+}
+
+func newSnapshotPosition(position Positionable) (snapshotPosition, bool) {
+	switch p := position.(type) {
+	case *SourcedClassAPositionReport:
+		return snapshotPosition{snapshotSource: newSnapshotSource(&p.SourceAndTime), ClassA: &p.ClassAPositionReport}, true
+	case *SourcedClassBPositionReport:
+		return snapshotPosition{snapshotSource: newSnapshotSource(&p.SourceAndTime), ClassB: &p.ClassBPositionReport}, true
+	case *SourcedExtendedClassBPositionReport:
+		return snapshotPosition{snapshotSource: newSnapshotSource(&p.SourceAndTime), ExtendedClassB: &p.ExtendedClassBPositionReport}, true
+	case *SourcedLongRangePositionReport:
+		return snapshotPosition{snapshotSource: newSnapshotSource(&p.SourceAndTime), LongRange: &p.LongRangePositionReport}, true
+	default:
+		return snapshotPosition{}, false
+	}
+}
+
+func (sp *snapshotPosition) positionable() Positionable {
+	st := sp.sourceAndTime()
+	switch {
+	case sp.ClassA != nil:
+		return &SourcedClassAPositionReport{*sp.ClassA, st}
+	case sp.ClassB != nil:
+		return &SourcedClassBPositionReport{*sp.ClassB, st}
+	case sp.ExtendedClassB != nil:
+		return &SourcedExtendedClassBPositionReport{*sp.ExtendedClassB, st}
+	case sp.LongRange != nil:
+		return &SourcedLongRangePositionReport{*sp.LongRange, st}
+	default:
+		return nil
+	}
+}
+
+// WriteSnapshot writes the ship histories to a gzipped JSON file at path. The file is
+// written alongside and then renamed, so a crash part way through leaves the previous
+// snapshot in place
+func (aisData *AISData) WriteSnapshot(path string) error {
+	snap := snapshot{Version: snapshotVersion, Written: aisData.Now()}
+	for _, sh := range aisData.ShipHistories() {
+		ship := snapshotShip{MMSI: sh.MMSI}
+		for _, position := range sh.Positions() {
+			if sp, ok := newSnapshotPosition(position); ok {
+				ship.Positions = append(ship.Positions, sp)
+			}
+		}
+
+		if vd := sh.VoyageData(); vd != nil {
+			ship.VoyageData = &snapshotVoyageData{newSnapshotSource(&vd.SourceAndTime), vd.StaticVoyageData}
+		}
+
+		snap.Ships = append(snap.Ships, ship)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	zw := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(zw).Encode(&snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	logger.Debugf("Wrote snapshot of %d ships to %s", len(snap.Ships), path)
+	return os.Rename(tmp.Name(), path)
+}
+
+// RestoreSnapshot loads the ship histories from the snapshot at path, discarding any
+// position older than PositionRetentionDur and any ship left without positions. A
+// missing snapshot isn't an error: there's just nothing to restore
+func (aisData *AISData) RestoreSnapshot(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		logger.Infof("No snapshot at %s, starting empty", path)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	var snap snapshot
+	if err := json.NewDecoder(zr).Decode(&snap); err != nil {
+		return err
+	}
+	if snap.Version != snapshotVersion {
+		return SnapshotVersionError{snap.Version}
+	}
+
+	// positions from the future are from some other clock's snapshot: a replay's, or the
+	// wall clock's when replaying
+	now := aisData.Now()
+	since := now.Add(-aisData.PositionRetentionDur)
+	var restored int
+	for _, ship := range snap.Ships {
+		history := NewShipHistory(ship.MMSI)
+		history.compression = aisData.TrackCompression
+		for _, sp := range ship.Positions {
+			position := sp.positionable()
+			if position == nil || position.ReceivedTime().Before(since) || position.ReceivedTime().After(now) {
+				continue
+			}
+			history.addPosition(position)
+		}
+
+		if len(history.positions) == 0 {
+			continue
+		}
+
+		if vd := ship.VoyageData; vd != nil {
+			history.setVoyageData(&SourcedStaticVoyageData{vd.StaticVoyageData, vd.sourceAndTime()})
+		} else {
+			aisData.seedVoyageData(history)
+		}
+
+		latest := history.positions[len(history.positions)-1].GetPositionReport()
+		aisData.Lock()
+		aisData.mmsiHistories[ship.MMSI] = history
+		aisData.Unlock()
+		aisData.spatial.update(ship.MMSI, latest.Lat, latest.Lon)
+		aisData.publish(ChangeEvent{Type: VesselAdded, MMSI: ship.MMSI, At: now})
+		restored++
+	}
+
+	logger.Infof("Restored %d of %d ships from the snapshot at %s", restored, len(snap.Ships), path)
+	return nil
+}
+
+// SnapshotPeriodically writes a snapshot to path every interval until ctx is cancelled,
+// then writes a final one
+func (aisData *AISData) SnapshotPeriodically(ctx context.Context, path string, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := aisData.WriteSnapshot(path); err != nil {
+				logger.WithError(err).Error("writing the final snapshot")
+			}
+			logger.Info("snapshot loop exiting")
+			return
+
+		case <-ticker.C:
+			if err := aisData.WriteSnapshot(path); err != nil {
+				logger.WithError(err).Warn("writing a snapshot")
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package shipdata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andmarios/aislib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tugsy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json.gz")
+
+	now := time.Now()
+	aisData := NewAISData()
+	stale := SourceAndTime{sourceName: "radio", receivedTime: now.Add(-aisData.PositionRetentionDur - time.Minute)}
+	fresh := SourceAndTime{sourceName: "radio", station: "r003669945", receivedTime: now}
+
+	aisData.AddPosition(&SourcedClassAPositionReport{aislib.ClassAPositionReport{PositionReport: aislib.PositionReport{MMSI: 367000001, Lat: 41.8}}, stale})
+	aisData.AddPosition(&SourcedClassAPositionReport{aislib.ClassAPositionReport{PositionReport: aislib.PositionReport{MMSI: 367000001, Lat: 41.81}}, fresh})
+	aisData.AddPosition(&SourcedLongRangePositionReport{LongRangePositionReport{PositionReport: aislib.PositionReport{MMSI: 367000002}}, stale})
+	aisData.UpdateStaticVoyageData(&SourcedStaticVoyageData{aislib.StaticVoyageData{MMSI: 367000001, VesselName: "JOHN P BROWN"}, fresh})
+
+	assert.NoError(t, aisData.WriteSnapshot(path))
+
+	restored := NewAISData()
+	assert.NoError(t, restored.RestoreSnapshot(path))
+
+	// the ship with only stale positions is gone, as is the other's stale position
+	histories := restored.ShipHistories()
+	assert.Equal(t, 1, len(histories))
+
+	positions := histories[0].Positions()
+	assert.Equal(t, 1, len(positions))
+	assert.IsType(t, &SourcedClassAPositionReport{}, positions[0])
+	assert.InDelta(t, 41.81, positions[0].GetPositionReport().Lat, 0.000001)
+	assert.Equal(t, "radio", positions[0].Source())
+	assert.True(t, now.Equal(positions[0].ReceivedTime()))
+	assert.Equal(t, "JOHN P BROWN", histories[0].VoyageData().VesselName)
+	assert.Equal(t, "r003669945", histories[0].VoyageData().Station())
+
+	// no snapshot, nothing to restore
+	assert.NoError(t, NewAISData().RestoreSnapshot(filepath.Join(dir, "missing.json.gz")))
+}
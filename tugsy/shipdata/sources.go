@@ -0,0 +1,309 @@
+package shipdata
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	TCPDialSourceType   = "tcp"
+	TCPListenSourceType = "tcp-server"
+	UDPSourceType       = "udp"
+	FileTailSourceType  = "file"
+	ReplaySourceType    = "replay"
+
+	maxUDPDatagramSize = 65535
+	fileTailPollPeriod = 500 * time.Millisecond
+)
+
+type UnknownSourceTypeError struct {
+	Type string
+}
+
+func (e UnknownSourceTypeError) Error() string {
+	return fmt.Sprintf("unknown AIS source type '%s'", e.Type)
+}
+
+// An AISSource supplies raw NMEA sentences to a RemoteAISServer. Run blocks, sending each
+// sentence it reads to the given channel, until the source's connection ends or ctx is
+// cancelled, closing any connections it has open before returning. It calls connected
+// once the connection is established (or the listener is bound) and returns an error only
+// if that never happened, so the caller can decide whether to keep retrying
+type AISSource interface {
+	Run(ctx context.Context, sentences chan<- string, connected func()) error
+}
+
+// newAISSource builds the AISSource described by a router's config
+func newAISSource(router *RemoteAISServer) (AISSource, error) {
+	switch router.Type {
+	case "", TCPDialSourceType:
+		return NewTCPDialSource(router.HostColonPort), nil
+	case TCPListenSourceType:
+		return NewTCPListenSource(router.HostColonPort), nil
+	case UDPSourceType:
+		return NewUDPSource(router.HostColonPort), nil
+	case FileTailSourceType:
+		return NewFileTailSource(router.Path), nil
+	case ReplaySourceType:
+		// the replay's clock is the one everything is measured against
+		clock := NewReplayClock(router.Speed)
+		router.aisData.Clock = clock
+		source := NewReplaySource(router.Path, router.Loop, router.StartOffset, clock)
+		source.primeClock()
+		return source, nil
+	default:
+		return nil, UnknownSourceTypeError{router.Type}
+	}
+}
+
+// closeOnDone closes c when ctx is cancelled, which unblocks any reads in progress. The
+// returned func must be called once c is no longer in use; it closes c if that hasn't
+// happened already
+func closeOnDone(ctx context.Context, c io.Closer) func() {
+	done := make(chan struct{})
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+
+		if err := c.Close(); err != nil {
+			logger.WithError(err).Debug("while closing AIS source connection")
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-closed
+	}
+}
+
+// scanSentences sends each line read from r to sentences until r is exhausted or fails,
+// or ctx is cancelled
+func scanSentences(ctx context.Context, r io.Reader, sentences chan<- string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			select {
+			case sentences <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// TCPDialSource connects to a remote host that serves NMEA sentences over TCP, e.g. the
+// network output of an AIS decoder or an internet feed
+type TCPDialSource struct {
+	HostColonPort string
+}
+
+func NewTCPDialSource(hostColonPort string) *TCPDialSource {
+	return &TCPDialSource{HostColonPort: hostColonPort}
+}
+
+func (s *TCPDialSource) Run(ctx context.Context, sentences chan<- string, connected func()) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.HostColonPort)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Dialed host %+v", s.HostColonPort)
+	connected()
+
+	release := closeOnDone(ctx, conn)
+	defer release()
+
+	scanSentences(ctx, conn, sentences)
+	if ctx.Err() == nil {
+		logger.Warnf("connection to host %s broken", s.HostColonPort)
+	}
+	return nil
+}
+
+// TCPListenSource accepts connections from any number of clients that push NMEA
+// sentences to us over TCP
+type TCPListenSource struct {
+	HostColonPort string
+}
+
+func NewTCPListenSource(hostColonPort string) *TCPListenSource {
+	return &TCPListenSource{HostColonPort: hostColonPort}
+}
+
+func (s *TCPListenSource) Run(ctx context.Context, sentences chan<- string, connected func()) error {
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", s.HostColonPort)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Listening for AIS clients on %s", s.HostColonPort)
+	connected()
+
+	release := closeOnDone(ctx, listener)
+	defer release()
+
+	var clients sync.WaitGroup
+	defer clients.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.WithError(err).Warnf("no longer accepting AIS clients on %s", s.HostColonPort)
+			}
+			return nil
+		}
+
+		logger.Infof("Accepted AIS client %s on %s", conn.RemoteAddr(), s.HostColonPort)
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			release := closeOnDone(ctx, conn)
+			defer release()
+
+			scanSentences(ctx, conn, sentences)
+			logger.Infof("AIS client %s disconnected", conn.RemoteAddr())
+		}()
+	}
+}
+
+// UDPSource listens for NMEA sentences sent as UDP datagrams, which is how most SDR
+// decoders (rtl-ais, AIS-catcher) forward what they hear. A datagram may hold several
+// sentences
+type UDPSource struct {
+	HostColonPort string
+}
+
+func NewUDPSource(hostColonPort string) *UDPSource {
+	return &UDPSource{HostColonPort: hostColonPort}
+}
+
+func (s *UDPSource) Run(ctx context.Context, sentences chan<- string, connected func()) error {
+	var lc net.ListenConfig
+	conn, err := lc.ListenPacket(ctx, "udp", s.HostColonPort)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Listening for AIS datagrams on %s", s.HostColonPort)
+	connected()
+
+	release := closeOnDone(ctx, conn)
+	defer release()
+
+	buf := make([]byte, maxUDPDatagramSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.WithError(err).Warnf("no longer reading AIS datagrams on %s", s.HostColonPort)
+			}
+			return nil
+		}
+
+		scanSentences(ctx, bytes.NewReader(buf[:n]), sentences)
+	}
+}
+
+// FileTailSource follows a file that some other process appends NMEA sentences to, in
+// the manner of 'tail -f'. Reading starts at the end of the file, and starts over from
+// the beginning if the file is truncated or replaced
+type FileTailSource struct {
+	Path string
+}
+
+func NewFileTailSource(path string) *FileTailSource {
+	return &FileTailSource{Path: path}
+}
+
+func (s *FileTailSource) Run(ctx context.Context, sentences chan<- string, connected func()) error {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Tailing AIS file %s", s.Path)
+	connected()
+
+	reader := bufio.NewReader(file)
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		partial += line
+
+		if err == nil {
+			if sentence := strings.TrimSpace(partial); sentence != "" {
+				select {
+				case sentences <- sentence:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			partial = ""
+			continue
+		}
+
+		if err != io.EOF {
+			logger.WithError(err).Warnf("while tailing AIS file %s", s.Path)
+			return nil
+		}
+
+		// at the end of what's been written so far. wait a bit, then check whether the
+		// file was truncated or replaced before reading more
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(fileTailPollPeriod):
+		}
+
+		if s.fileChanged(file, offset) {
+			logger.Infof("AIS file %s was truncated or replaced, reopening", s.Path)
+			reopened, err := os.Open(s.Path)
+			if err != nil {
+				logger.WithError(err).Warnf("could not reopen AIS file %s", s.Path)
+				return nil
+			}
+
+			_ = file.Close()
+			file = reopened
+			reader.Reset(file)
+			offset = 0
+			partial = ""
+		}
+	}
+}
+
+func (s *FileTailSource) fileChanged(file *os.File, offset int64) bool {
+	current, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	onDisk, err := os.Stat(s.Path)
+	if err != nil {
+		// the file is gone for the moment. keep reading the one we have open
+		return false
+	}
+
+	return os.SameFile(current, onDisk) == false || onDisk.Size() < offset
+}
@@ -0,0 +1,73 @@
+package shipdata
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownSourceType(t *testing.T) {
+	_, err := newAISSource(&RemoteAISServer{Type: "carrier-pigeon"})
+	assert.Equal(t, UnknownSourceTypeError{"carrier-pigeon"}, err)
+}
+
+func TestUDPSourceSplitsDatagrams(t *testing.T) {
+	source := NewUDPSource("127.0.0.1:0")
+	sentences := make(chan string, 10)
+
+	// listen on an ephemeral port, then find out which one it was
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	source.HostColonPort = conn.LocalAddr().String()
+	_ = conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- source.Run(ctx, sentences, func() {}) }()
+
+	sender, err := net.Dial("udp", source.HostColonPort)
+	assert.NoError(t, err)
+	defer sender.Close()
+
+	// the source may not be listening yet, so keep sending until something arrives
+	var first string
+	for first == "" {
+		_, _ = sender.Write([]byte("!AIVDM,one\r\n!AIVDM,two\r\n"))
+		select {
+		case first = <-sentences:
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	assert.Equal(t, "!AIVDM,one", first)
+	assert.Equal(t, "!AIVDM,two", <-sentences)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRunExitsOnCancel(t *testing.T) {
+	router := &RemoteAISServer{
+		SourceName: "test",
+		aisData:    NewAISData(),
+		source:     NewTCPListenSource("127.0.0.1:0"),
+		stats:      SourceStats{ByType: make(map[uint8]uint64)},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		router.Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("router did not exit after cancellation")
+	}
+}
@@ -0,0 +1,176 @@
+package shipdata
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	defaultGridCellDegrees = 0.005 // about 550m north to south
+)
+
+type gridCell struct {
+	row, col int
+}
+
+type indexedPosition struct {
+	lat, lon float64
+	cell     gridCell
+}
+
+// SpatialIndex is a uniform grid over the vessels' latest positions, for finding the
+// vessels in an area or near a point without looking at all of them. Cells are a fixed
+// number of degrees on a side: a harbor is small enough that they're roughly square
+type SpatialIndex struct {
+	sync.Mutex
+
+	cellDegrees float64
+	cells       map[gridCell]map[uint32]struct{}
+	positions   map[uint32]indexedPosition
+}
+
+func NewSpatialIndex(cellDegrees float64) *SpatialIndex {
+	if cellDegrees <= 0 {
+		cellDegrees = defaultGridCellDegrees
+	}
+	return &SpatialIndex{
+		cellDegrees: cellDegrees,
+		cells:       make(map[gridCell]map[uint32]struct{}),
+		positions:   make(map[uint32]indexedPosition),
+	}
+}
+
+func (idx *SpatialIndex) cellOf(lat, lon float64) gridCell {
+	return gridCell{int(math.Floor(lat / idx.cellDegrees)), int(math.Floor(lon / idx.cellDegrees))}
+}
+
+// update moves the vessel to its latest position
+func (idx *SpatialIndex) update(mmsi uint32, lat, lon float64) {
+	idx.Lock()
+	defer idx.Unlock()
+
+	cell := idx.cellOf(lat, lon)
+	if prev, ok := idx.positions[mmsi]; ok && prev.cell != cell {
+		idx.removeFromCell(mmsi, prev.cell)
+	}
+
+	members, ok := idx.cells[cell]
+	if ok == false {
+		members = make(map[uint32]struct{})
+		idx.cells[cell] = members
+	}
+	members[mmsi] = struct{}{}
+	idx.positions[mmsi] = indexedPosition{lat, lon, cell}
+}
+
+// remove drops the vessel from the index
+func (idx *SpatialIndex) remove(mmsi uint32) {
+	idx.Lock()
+	defer idx.Unlock()
+
+	if prev, ok := idx.positions[mmsi]; ok {
+		idx.removeFromCell(mmsi, prev.cell)
+		delete(idx.positions, mmsi)
+	}
+}
+
+// called with idx locked
+func (idx *SpatialIndex) removeFromCell(mmsi uint32, cell gridCell) {
+	members := idx.cells[cell]
+	delete(members, mmsi)
+	if len(members) == 0 {
+		delete(idx.cells, cell)
+	}
+}
+
+// InBounds returns the vessels whose latest positions are inside box
+func (idx *SpatialIndex) InBounds(box BoundingBox) []uint32 {
+	idx.Lock()
+	defer idx.Unlock()
+
+	sw, ne := idx.cellOf(box.South, box.West), idx.cellOf(box.North, box.East)
+	var mmsis []uint32
+
+	// when the box covers more cells than there are occupied ones, walk those instead
+	if (ne.row-sw.row+1)*(ne.col-sw.col+1) > len(idx.cells) {
+		for mmsi, p := range idx.positions {
+			if box.Contains(p.lat, p.lon) {
+				mmsis = append(mmsis, mmsi)
+			}
+		}
+		return mmsis
+	}
+
+	for row := sw.row; row <= ne.row; row++ {
+		for col := sw.col; col <= ne.col; col++ {
+			for mmsi := range idx.cells[gridCell{row, col}] {
+				if p := idx.positions[mmsi]; box.Contains(p.lat, p.lon) {
+					mmsis = append(mmsis, mmsi)
+				}
+			}
+		}
+	}
+	return mmsis
+}
+
+// Nearest returns up to k vessels closest to lat/lon, nearest first. It searches rings of
+// cells outward from lat/lon until no unsearched cell could hold anything closer, or
+// until the rings hold more cells than are occupied and it's quicker to look at them all
+func (idx *SpatialIndex) Nearest(lat, lon float64, k int) []uint32 {
+	if k <= 0 {
+		return nil
+	}
+
+	idx.Lock()
+	defer idx.Unlock()
+
+	type candidate struct {
+		mmsi   uint32
+		meters float64
+	}
+	var found []candidate
+
+	// the least distance from lat/lon to anything outside the rings searched so far is
+	// at least this many meters per ring: a cell's narrower side, east to west
+	ringMeters := idx.cellDegrees * earthRadiusMeters * math.Pi / 180 * math.Cos(toRadians(lat))
+	center := idx.cellOf(lat, lon)
+
+	for ring := 0; len(found) < len(idx.positions); ring++ {
+		if side := 2*ring + 1; side*side > 4*len(idx.cells) {
+			found = found[:0]
+			for mmsi, p := range idx.positions {
+				found = append(found, candidate{mmsi, distanceMeters(lat, lon, p.lat, p.lon)})
+			}
+			sort.Slice(found, func(i, j int) bool { return found[i].meters < found[j].meters })
+			break
+		}
+
+		for row := center.row - ring; row <= center.row+ring; row++ {
+			for col := center.col - ring; col <= center.col+ring; col++ {
+				// only the cells on the ring's edge are new
+				if row != center.row-ring && row != center.row+ring && col != center.col-ring && col != center.col+ring {
+					continue
+				}
+				for mmsi := range idx.cells[gridCell{row, col}] {
+					p := idx.positions[mmsi]
+					found = append(found, candidate{mmsi, distanceMeters(lat, lon, p.lat, p.lon)})
+				}
+			}
+		}
+
+		sort.Slice(found, func(i, j int) bool { return found[i].meters < found[j].meters })
+		if len(found) >= k && found[k-1].meters <= float64(ring)*ringMeters {
+			break
+		}
+	}
+
+	if len(found) > k {
+		found = found[:k]
+	}
+	mmsis := make([]uint32, len(found))
+	for i, c := range found {
+		mmsis[i] = c.mmsi
+	}
+	return mmsis
+}
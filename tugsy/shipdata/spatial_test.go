@@ -0,0 +1,42 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpatialIndex(t *testing.T) {
+	idx := NewSpatialIndex(0.005)
+	idx.update(1, 41.800, -71.390)
+	idx.update(2, 41.801, -71.391)
+	idx.update(3, 41.780, -71.380)
+	idx.update(4, 41.700, -71.300)
+
+	assert.Equal(t, []uint32{3}, idx.InBounds(BoundingBox{South: 41.77, West: -71.385, North: 41.79, East: -71.375}))
+	assert.Equal(t, 3, len(idx.InBounds(BoundingBox{South: 41.77, West: -71.40, North: 41.81, East: -71.37})))
+
+	assert.Equal(t, []uint32{1, 2, 3}, idx.Nearest(41.7999, -71.3899, 3))
+	assert.Equal(t, []uint32{4}, idx.Nearest(41.69, -71.29, 1))
+
+	// moving a vessel moves it between cells
+	idx.update(4, 41.8005, -71.3905)
+	assert.Equal(t, []uint32{4}, idx.Nearest(41.8005, -71.3905, 1))
+	assert.Equal(t, 0, len(idx.InBounds(BoundingBox{South: 41.69, West: -71.31, North: 41.71, East: -71.29})))
+
+	idx.remove(4)
+	assert.Equal(t, []uint32{1, 2, 3}, idx.Nearest(41.8001, -71.3901, 5))
+}
+
+func TestShipsInBounds(t *testing.T) {
+	aisData := NewAISData()
+	now := time.Now()
+	aisData.AddPosition(positionAt(41.8, -71.39, now))
+
+	ships := aisData.ShipsInBounds(BoundingBox{South: 41.7, West: -71.4, North: 41.9, East: -71.3})
+	assert.Equal(t, 1, len(ships))
+	assert.Equal(t, uint32(1), ships[0].MMSI)
+	assert.Equal(t, 0, len(aisData.ShipsInBounds(BoundingBox{South: 41.0, West: -71.4, North: 41.1, East: -71.3})))
+	assert.Equal(t, ships, aisData.NearestShips(41.0, -71.0, 2))
+}
@@ -0,0 +1,46 @@
+package shipdata
+
+import (
+	"fmt"
+	"reflect"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// subscriberList holds the channels a detector sends its events to. Each detector's
+// Subscribe and Unsubscribe wrap it in the detector's own event type, so the channels are
+// held untyped. It isn't safe for concurrent use: the detector's lock guards it
+type subscriberList struct {
+	kind     string // what the events are, for logging
+	channels []reflect.Value
+}
+
+// add starts sending events to a channel of the detector's event type
+func (l *subscriberList) add(events interface{}) {
+	l.channels = append(l.channels, reflect.ValueOf(events))
+}
+
+// remove stops sending events to a channel given to add, and closes it. The channel may
+// be passed as its receive-only side
+func (l *subscriberList) remove(events interface{}) {
+	ptr := reflect.ValueOf(events).Pointer()
+	for i, ch := range l.channels {
+		if ch.Pointer() == ptr {
+			ch.Close()
+			l.channels = append(l.channels[:i], l.channels[i+1:]...)
+			return
+		}
+	}
+}
+
+// publish sends an event to every channel. Events are dropped rather than wait for a
+// subscriber that has fallen a whole buffer behind
+func (l *subscriberList) publish(event fmt.Stringer) {
+	logger.Debug(event.String())
+	value := reflect.ValueOf(event)
+	for _, ch := range l.channels {
+		if ch.TrySend(value) == false {
+			logger.Warnf("a %s subscriber is falling behind, dropping %s", l.kind, event)
+		}
+	}
+}
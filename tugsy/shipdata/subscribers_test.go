@@ -0,0 +1,28 @@
+package shipdata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriberListDropsForSlowSubscribers(t *testing.T) {
+	subscribers := subscriberList{kind: "zone event"}
+	zone := &Zone{Name: "berth"}
+	slow, fast := make(chan ZoneEvent, 1), make(chan ZoneEvent, 2)
+	subscribers.add(slow)
+	subscribers.add(fast)
+
+	subscribers.publish(ZoneEvent{Type: ZoneEnter, Zone: zone, MMSI: 1})
+	subscribers.publish(ZoneEvent{Type: ZoneExit, Zone: zone, MMSI: 1})
+	assert.Equal(t, 1, len(slow))
+	assert.Equal(t, 2, len(fast))
+	assert.Equal(t, ZoneEnter, (<-slow).Type)
+
+	var receiveOnly <-chan ZoneEvent = slow
+	subscribers.remove(receiveOnly)
+	subscribers.publish(ZoneEvent{Type: ZoneEnter, Zone: zone, MMSI: 1})
+	_, open := <-slow
+	assert.False(t, open)
+	assert.Equal(t, 2, len(fast))
+}
@@ -0,0 +1,252 @@
+package shipdata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// how long a tag block is kept waiting for aislib.Router to assemble its message
+const tagRetentionDur = 1 * time.Minute
+
+// A TagBlock is the NMEA 4.0 metadata some feeds prefix to their sentences, e.g.
+//
+//	\s:2573535,c:1671620143*0F\!AIVDM,1,1,,B,...
+//
+// Fields missing from the tag block are left at their zero values
+type TagBlock struct {
+	Time        time.Time // c: when the sentence was received at the station
+	Source      string    // s: the id of the station that received it
+	Destination string    // d:
+	LineCount   int       // n:
+	Text        string    // t:
+
+	// g: the sentence's place in a group of sentences that share this tag block
+	GroupLine, GroupSize, GroupID int
+}
+
+type TagBlockError struct {
+	Line   string
+	Reason string
+}
+
+func (e TagBlockError) Error() string {
+	return fmt.Sprintf("bad tag block (%s) in '%s'", e.Reason, e.Line)
+}
+
+// ParseTagBlock splits a line into its tag block and its sentence. A line without a tag
+// block returns a nil TagBlock. If the tag block is malformed the sentence is returned
+// along with the error, since the sentence may well be usable by itself
+func ParseTagBlock(line string) (*TagBlock, string, error) {
+	if strings.HasPrefix(line, `\`) == false {
+		return nil, line, nil
+	}
+
+	end := strings.Index(line[1:], `\`)
+	if end < 0 {
+		return nil, line, TagBlockError{line, "unterminated"}
+	}
+	block, sentence := line[1:end+1], line[end+2:]
+
+	star := strings.LastIndex(block, "*")
+	if star < 0 {
+		return nil, sentence, TagBlockError{line, "no checksum"}
+	}
+	fields, checksum := block[:star], block[star+1:]
+
+	var sum byte
+	for i := 0; i < len(fields); i++ {
+		sum ^= fields[i]
+	}
+	if want, err := strconv.ParseUint(checksum, 16, 8); err != nil || byte(want) != sum {
+		return nil, sentence, TagBlockError{line, "checksum mismatch"}
+	}
+
+	tag := &TagBlock{}
+	for _, field := range strings.Split(fields, ",") {
+		kv := strings.SplitN(field, ":", 2)
+		if len(kv) != 2 {
+			return nil, sentence, TagBlockError{line, "field '" + field + "'"}
+		}
+
+		var err error
+		switch kv[0] {
+		case "c":
+			var secs int64
+			secs, err = strconv.ParseInt(kv[1], 10, 64)
+			if secs > 1e11 {
+				// some feeds send milliseconds
+				tag.Time = time.Unix(secs/1000, (secs%1000)*int64(time.Millisecond))
+			} else {
+				tag.Time = time.Unix(secs, 0)
+			}
+		case "s":
+			tag.Source = kv[1]
+		case "d":
+			tag.Destination = kv[1]
+		case "n":
+			tag.LineCount, err = strconv.Atoi(kv[1])
+		case "t":
+			tag.Text = kv[1]
+		case "g":
+			parts := strings.Split(kv[1], "-")
+			if len(parts) != 3 {
+				return nil, sentence, TagBlockError{line, "group '" + kv[1] + "'"}
+			}
+			if tag.GroupLine, err = strconv.Atoi(parts[0]); err == nil {
+				if tag.GroupSize, err = strconv.Atoi(parts[1]); err == nil {
+					tag.GroupID, err = strconv.Atoi(parts[2])
+				}
+			}
+		}
+
+		if err != nil {
+			return nil, sentence, TagBlockError{line, "field '" + field + "'"}
+		}
+	}
+
+	return tag, sentence, nil
+}
+
+type taggedPayload struct {
+	tag     *TagBlock
+	payload string // for fragments, the payload so far
+	added   time.Time
+}
+
+// A tagTracker strips the tag blocks from sentences on their way to aislib.Router,
+// remembering them by the payload of the message each sentence is part of. The router
+// only hands back the assembled message, so that's the only way to match the two up
+// again. A message split over several sentences takes the first tag block in its group
+type tagTracker struct {
+	sync.Mutex
+
+	fragments map[string]*taggedPayload   // partial payloads by sequential message id
+	payloads  map[string][]*taggedPayload // complete payloads, in arrival order
+	lastSweep time.Time
+}
+
+func newTagTracker() *tagTracker {
+	return &tagTracker{
+		fragments: make(map[string]*taggedPayload),
+		payloads:  make(map[string][]*taggedPayload),
+	}
+}
+
+// strip passes every line from in to out without its tag block, closing out once in
+// has been closed
+func (t *tagTracker) strip(in <-chan string, out chan<- string) {
+	defer close(out)
+
+	for line := range in {
+		tag, sentence, err := ParseTagBlock(line)
+		if err != nil {
+			logger.WithError(err).Debug("ignoring tag block")
+		}
+
+		t.track(tag, sentence)
+		out <- sentence
+	}
+}
+
+// track remembers the tag block for the sentence's message. Untagged sentences are only
+// tracked if they continue a tagged message
+func (t *tagTracker) track(tag *TagBlock, sentence string) {
+	if star := strings.LastIndex(sentence, "*"); star >= 0 {
+		sentence = sentence[:star]
+	}
+
+	// !AIVDM,count,number,sequence id,channel,payload,fill bits
+	fields := strings.Split(sentence, ",")
+	if len(fields) != 7 {
+		return
+	}
+	count, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return
+	}
+	number, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return
+	}
+	seqID, payload := fields[3], fields[5]
+
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now()
+	t.sweep(now)
+
+	if count == 1 {
+		if tag != nil {
+			t.payloads[payload] = append(t.payloads[payload], &taggedPayload{tag: tag, added: now})
+		}
+		return
+	}
+
+	if number == 1 {
+		delete(t.fragments, seqID)
+		if tag != nil {
+			t.fragments[seqID] = &taggedPayload{tag: tag, added: now}
+		}
+	}
+
+	fragment, ok := t.fragments[seqID]
+	if ok == false {
+		return
+	}
+
+	fragment.payload += payload
+	if number == count {
+		delete(t.fragments, seqID)
+		t.payloads[fragment.payload] = append(t.payloads[fragment.payload], fragment)
+	}
+}
+
+// lookup returns the oldest tag block remembered for the payload, forgetting it, or nil
+// if there's none
+func (t *tagTracker) lookup(payload string) *TagBlock {
+	if t == nil {
+		return nil
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	tagged, ok := t.payloads[payload]
+	if ok == false {
+		return nil
+	}
+
+	if len(tagged) == 1 {
+		delete(t.payloads, payload)
+	} else {
+		t.payloads[payload] = tagged[1:]
+	}
+	return tagged[0].tag
+}
+
+// sweep forgets the tag blocks of sentences that never made it into a message, e.g.
+// because a fragment was lost. It's only run once per tagRetentionDur
+func (t *tagTracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < tagRetentionDur {
+		return
+	}
+	t.lastSweep = now
+
+	since := now.Add(-tagRetentionDur)
+	for seqID, fragment := range t.fragments {
+		if fragment.added.Before(since) {
+			delete(t.fragments, seqID)
+		}
+	}
+	for payload, tagged := range t.payloads {
+		if tagged[len(tagged)-1].added.Before(since) {
+			delete(t.payloads, payload)
+		}
+	}
+}
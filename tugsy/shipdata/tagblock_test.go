@@ -0,0 +1,67 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTagBlock(t *testing.T) {
+	sentence := "!AIVDM,1,1,,A,15NQuePP00rq9v2GsD?emOwh20Rf,0*72"
+
+	tag, s, err := ParseTagBlock(`\g:1-2-73874,n:157036,s:r003669945,c:1241544035*4A\` + sentence)
+	assert.NoError(t, err)
+	assert.Equal(t, sentence, s)
+	assert.Equal(t, time.Unix(1241544035, 0), tag.Time)
+	assert.Equal(t, "r003669945", tag.Source)
+	assert.Equal(t, 157036, tag.LineCount)
+	assert.Equal(t, 1, tag.GroupLine)
+	assert.Equal(t, 2, tag.GroupSize)
+	assert.Equal(t, 73874, tag.GroupID)
+
+	tag, _, err = ParseTagBlock(`\s:rORBCOMM,c:1671620143000*2E\` + sentence)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1671620143, 0), tag.Time)
+
+	tag, s, err = ParseTagBlock(sentence)
+	assert.NoError(t, err)
+	assert.Nil(t, tag)
+	assert.Equal(t, sentence, s)
+
+	// the sentence is still usable when the tag block isn't
+	tag, s, err = ParseTagBlock(`\s:2573535,c:1671620143*0E\` + sentence)
+	assert.Error(t, err)
+	assert.Nil(t, tag)
+	assert.Equal(t, sentence, s)
+}
+
+func TestTagTrackerAssemblesFragments(t *testing.T) {
+	tracker := newTagTracker()
+	in, out := make(chan string, 3), make(chan string, 3)
+
+	in <- `\g:1-2-73874,s:r003669945,c:1241544035*34\!AIVDM,2,1,3,B,55P5TL01VIaAL@7WKO@mBplU@<PDhh000000001S;AJ::4A80?4i@E53,0*3E`
+	in <- `\g:2-2-73874*62\!AIVDM,2,2,3,B,1@0000000000000,2*55`
+	in <- "!AIVDM,1,1,,A,15NQuePP00rq9v2GsD?emOwh20Rf,0*72"
+	close(in)
+	tracker.strip(in, out)
+
+	var sentences []string
+	for s := range out {
+		sentences = append(sentences, s)
+	}
+	assert.Equal(t, "!AIVDM,2,2,3,B,1@0000000000000,2*55", sentences[1])
+
+	tag := tracker.lookup("55P5TL01VIaAL@7WKO@mBplU@<PDhh000000001S;AJ::4A80?4i@E531@0000000000000")
+	assert.Equal(t, "r003669945", tag.Source)
+	assert.Equal(t, time.Unix(1241544035, 0), tag.Time)
+
+	st := SourceAndTime{sourceName: "feed", receivedTime: time.Now()}
+	st.withTagBlock(tag)
+	assert.Equal(t, time.Unix(1241544035, 0), st.ReceivedTime())
+	assert.Equal(t, "r003669945", st.Station())
+
+	// each tag block is only handed out once, and untagged messages have none
+	assert.Nil(t, tracker.lookup("55P5TL01VIaAL@7WKO@mBplU@<PDhh000000001S;AJ::4A80?4i@E531@0000000000000"))
+	assert.Nil(t, tracker.lookup("15NQuePP00rq9v2GsD?emOwh20Rf"))
+}
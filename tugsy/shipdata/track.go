@@ -0,0 +1,156 @@
+package shipdata
+
+import (
+	"math"
+
+	"github.com/joemadeus/tugsy/tugsy/config"
+)
+
+const (
+	defaultTrackStationaryMeters = 10
+	defaultTrackToleranceMeters  = 15
+	defaultTrackMaxPositions     = 500
+)
+
+// TrackCompression bounds the number of positions a ShipHistory holds. The latest
+// position is always kept exactly as it was reported
+type TrackCompression struct {
+	// a position this close to the two before it replaces the one before it, so that a
+	// moored or anchored vessel's track is just its first and latest positions. Zero
+	// disables this
+	StationaryMeters float64
+
+	// once a track holds more than MaxPositions, it's simplified with Douglas-Peucker,
+	// starting with ToleranceMeters and doubling it until the track is down to three
+	// quarters of MaxPositions. If that isn't enough the oldest positions are dropped.
+	// Zero MaxPositions disables this
+	ToleranceMeters float64
+	MaxPositions    int
+}
+
+func DefaultTrackCompression() TrackCompression {
+	return TrackCompression{
+		StationaryMeters: defaultTrackStationaryMeters,
+		ToleranceMeters:  defaultTrackToleranceMeters,
+		MaxPositions:     defaultTrackMaxPositions,
+	}
+}
+
+// TrackCompressionFromConfig reads the trackStationaryMeters, trackToleranceMeters and
+// trackMaxPositions keys, using the defaults for any that aren't set
+func TrackCompressionFromConfig(cfg *config.Config) TrackCompression {
+	tc := DefaultTrackCompression()
+	if cfg.IsSet("trackStationaryMeters") {
+		tc.StationaryMeters = cfg.GetFloat64("trackStationaryMeters")
+	}
+	if cfg.IsSet("trackToleranceMeters") {
+		tc.ToleranceMeters = cfg.GetFloat64("trackToleranceMeters")
+	}
+	if cfg.IsSet("trackMaxPositions") {
+		tc.MaxPositions = cfg.GetInt("trackMaxPositions")
+	}
+	return tc
+}
+
+// compress applies the compression to a track that has just had report appended to it,
+// returning the compressed track
+func (tc TrackCompression) compress(positions []Positionable) []Positionable {
+	n := len(positions)
+	if tc.StationaryMeters > 0 && n >= 3 &&
+		metersBetween(positions[n-1], positions[n-2]) < tc.StationaryMeters &&
+		metersBetween(positions[n-2], positions[n-3]) < tc.StationaryMeters {
+
+		positions[n-2] = positions[n-1]
+		positions[n-1] = nil
+		positions = positions[:n-1]
+	}
+
+	if tc.MaxPositions <= 0 || len(positions) <= tc.MaxPositions {
+		return positions
+	}
+
+	target := tc.MaxPositions * 3 / 4
+	tolerance := tc.ToleranceMeters
+	if tolerance <= 0 {
+		tolerance = 1
+	}
+
+	simplified := positions
+	for i := 0; i < 8 && len(simplified) > target; i++ {
+		simplified = douglasPeucker(simplified, tolerance)
+		tolerance *= 2
+	}
+
+	if len(simplified) > target {
+		simplified = simplified[len(simplified)-target:]
+	}
+
+	// copy into a new slice so the dropped positions can be collected
+	compressed := make([]Positionable, len(simplified), tc.MaxPositions+1)
+	copy(compressed, simplified)
+	return compressed
+}
+
+// douglasPeucker returns the positions that are needed to keep the track within
+// toleranceMeters of the original. The first and last positions are always kept
+func douglasPeucker(positions []Positionable, toleranceMeters float64) []Positionable {
+	if len(positions) < 3 {
+		return positions
+	}
+
+	keep := make([]bool, len(positions))
+	keep[0], keep[len(positions)-1] = true, true
+
+	// an explicit stack rather than recursion, since tracks can be long
+	type span struct{ first, last int }
+	stack := []span{{0, len(positions) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		farthest, farthestMeters := -1, toleranceMeters
+		for i := s.first + 1; i < s.last; i++ {
+			if d := metersFromSegment(positions[i], positions[s.first], positions[s.last]); d > farthestMeters {
+				farthest, farthestMeters = i, d
+			}
+		}
+
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, span{s.first, farthest}, span{farthest, s.last})
+		}
+	}
+
+	kept := make([]Positionable, 0, len(positions))
+	for i, position := range positions {
+		if keep[i] {
+			kept = append(kept, position)
+		}
+	}
+	return kept
+}
+
+func metersBetween(a, b Positionable) float64 {
+	pa, pb := a.GetPositionReport(), b.GetPositionReport()
+	return distanceMeters(pa.Lat, pa.Lon, pb.Lat, pb.Lon)
+}
+
+// metersFromSegment returns the distance from p to the segment between a and b. Over the
+// length of a track segment the earth is flat enough to project onto a plane at a
+func metersFromSegment(p, a, b Positionable) float64 {
+	pp, pa, pb := p.GetPositionReport(), a.GetPositionReport(), b.GetPositionReport()
+
+	metersPerDegLat := earthRadiusMeters * math.Pi / 180
+	metersPerDegLon := metersPerDegLat * math.Cos(toRadians(pa.Lat))
+	px, py := (pp.Lon-pa.Lon)*metersPerDegLon, (pp.Lat-pa.Lat)*metersPerDegLat
+	bx, by := (pb.Lon-pa.Lon)*metersPerDegLon, (pb.Lat-pa.Lat)*metersPerDegLat
+
+	lengthSq := bx*bx + by*by
+	if lengthSq == 0 {
+		return math.Hypot(px, py)
+	}
+
+	// the projection of p onto the segment, clamped to its ends
+	t := math.Max(0, math.Min(1, (px*bx+py*by)/lengthSq))
+	return math.Hypot(px-t*bx, py-t*by)
+}
@@ -0,0 +1,66 @@
+package shipdata
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/andmarios/aislib"
+	"github.com/stretchr/testify/assert"
+)
+
+func positionAt(lat, lon float64, received time.Time) *MockPositionReport {
+	return &MockPositionReport{receivedTime: received, positionReport: &aislib.PositionReport{MMSI: 1, Lat: lat, Lon: lon}}
+}
+
+func TestStationaryPositionsCollapse(t *testing.T) {
+	now := time.Now()
+	sh := NewShipHistory(1)
+
+	first := positionAt(41.8, -71.39, now)
+	sh.addPosition(first)
+	var last *MockPositionReport
+	for i := 1; i <= 100; i++ {
+		// jitter of a meter or so, as from a moored vessel's GPS
+		last = positionAt(41.8+float64(i%3)*0.00001, -71.39, now.Add(time.Duration(i)*time.Second))
+		sh.addPosition(last)
+	}
+
+	assert.Equal(t, 2, len(sh.positions))
+	assert.Equal(t, first, sh.positions[0])
+	assert.Equal(t, last, sh.positions[1])
+}
+
+func TestLongTracksAreBounded(t *testing.T) {
+	now := time.Now()
+	sh := NewShipHistory(1)
+	sh.compression = TrackCompression{StationaryMeters: 10, ToleranceMeters: 15, MaxPositions: 100}
+
+	// a straight run followed by a turn, with a report every 50m or so at about ten knots
+	var last *MockPositionReport
+	for i := 0; i < 400; i++ {
+		lat, lon := 41.7+float64(i)*0.0005, -71.39
+		if i >= 200 {
+			lat, lon = 41.8, -71.39+float64(i-200)*0.0006
+		}
+		last = positionAt(lat, lon, now.Add(time.Duration(i)*10*time.Second))
+		sh.addPosition(last)
+	}
+
+	assert.True(t, len(sh.positions) <= 100)
+	assert.Equal(t, last, sh.positions[len(sh.positions)-1])
+
+	// the corner survives simplification
+	var corner bool
+	for _, p := range sh.positions {
+		if math.Abs(p.GetPositionReport().Lat-41.8) < 0.0001 && math.Abs(p.GetPositionReport().Lon+71.39) < 0.0001 {
+			corner = true
+		}
+	}
+	assert.True(t, corner)
+
+	// and the track is still in time order, which pruning depends on
+	for i := 1; i < len(sh.positions); i++ {
+		assert.True(t, sh.positions[i].ReceivedTime().After(sh.positions[i-1].ReceivedTime()))
+	}
+}
@@ -0,0 +1,340 @@
+package shipdata
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	TugJobStarted TugJobEventType = iota
+	TugJoined
+	TugJobEnded
+)
+
+const (
+	tugShipType             = 52
+	escortMeters            = 300 // a tug on a hawser can be well astern of the antenna
+	escortMinSpeedKts       = 1.5
+	escortSpeedToleranceKts = 3
+	escortMaxReportAge      = 2 * time.Minute // between the tug's report and the vessel's
+	escortConfirm           = 2 * time.Minute // in formation this long is a tug job
+	escortLapse             = 3 * time.Minute // out of formation this long ends it
+	minEscortedLengthMeters = 80
+	defaultTugJobLogSize    = 10
+)
+
+type TugJobEventType int
+
+func (t TugJobEventType) String() string {
+	switch t {
+	case TugJobStarted:
+		return "started"
+	case TugJoined:
+		return "joined by a tug"
+	case TugJobEnded:
+		return "ended"
+	default:
+		return "unknown"
+	}
+}
+
+// A TugJob is one or more tugs escorting a large cargo vessel or tanker. End is zero
+// while the job is under way
+type TugJob struct {
+	ID         uint64
+	Vessel     uint32
+	VesselName string
+	Tugs       []uint32
+	Start      time.Time
+	End        time.Time
+}
+
+func (j TugJob) Active() bool {
+	return j.End.IsZero()
+}
+
+func (j TugJob) copy() TugJob {
+	j.Tugs = append([]uint32(nil), j.Tugs...)
+	return j
+}
+
+type TugJobEvent struct {
+	Type TugJobEventType
+	Job  TugJob
+}
+
+func (e TugJobEvent) String() string {
+	return fmt.Sprintf("tug job %d escorting MMSI %d %s", e.Job.ID, e.Job.Vessel, e.Type)
+}
+
+// a tug seen in formation with a vessel, from since until last
+type escortPair struct {
+	since time.Time
+	last  time.Time
+}
+
+type escortKey struct {
+	tug, vessel uint32
+}
+
+// TugJobDetector watches for tugs (ship type 52) moving in close formation with large
+// cargo vessels and tankers. A tug that keeps station with a vessel for a couple of
+// minutes starts a tug job, or joins the one already under way; the job ends once none
+// of its tugs has been in formation for a few minutes. Finished jobs are kept in a
+// rolling log
+type TugJobDetector struct {
+	sync.Mutex
+
+	LogSize int
+
+	pairs       map[escortKey]*escortPair
+	active      map[uint32]*TugJob // by escorted vessel
+	log         []TugJob           // finished jobs, oldest first
+	lastID      uint64
+	subscribers subscriberList
+}
+
+func NewTugJobDetector() *TugJobDetector {
+	return &TugJobDetector{
+		LogSize:     defaultTugJobLogSize,
+		pairs:       make(map[escortKey]*escortPair),
+		active:      make(map[uint32]*TugJob),
+		subscribers: subscriberList{kind: "tug job"},
+	}
+}
+
+// Subscribe returns a channel that receives tug jobs as they start, gain tugs and end,
+// with room for buffer events
+func (d *TugJobDetector) Subscribe(buffer int) <-chan TugJobEvent {
+	d.Lock()
+	defer d.Unlock()
+
+	events := make(chan TugJobEvent, buffer)
+	d.subscribers.add(events)
+	return events
+}
+
+// Unsubscribe stops sending events to a channel returned by Subscribe, and closes it
+func (d *TugJobDetector) Unsubscribe(events <-chan TugJobEvent) {
+	d.Lock()
+	defer d.Unlock()
+	d.subscribers.remove(events)
+}
+
+// Recent returns the tug jobs under way, then the finished ones, newest first
+func (d *TugJobDetector) Recent() []TugJob {
+	d.Lock()
+	defer d.Unlock()
+
+	jobs := make([]TugJob, 0, len(d.active)+len(d.log))
+	for _, job := range d.active {
+		jobs = append(jobs, job.copy())
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Start.After(jobs[j].Start) })
+
+	for i := len(d.log) - 1; i >= 0; i-- {
+		jobs = append(jobs, d.log[i].copy())
+	}
+	return jobs
+}
+
+// Participants returns the MMSIs of every vessel and tug in a job that's under way
+func (d *TugJobDetector) Participants() map[uint32]struct{} {
+	d.Lock()
+	defer d.Unlock()
+
+	mmsis := make(map[uint32]struct{})
+	for _, job := range d.active {
+		mmsis[job.Vessel] = struct{}{}
+		for _, tug := range job.Tugs {
+			mmsis[tug] = struct{}{}
+		}
+	}
+	return mmsis
+}
+
+// escorting returns true if the two vessels are in the same tug job that's under way
+func (d *TugJobDetector) escorting(a, b uint32) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	for _, job := range d.active {
+		if job.Vessel != a && job.Vessel != b {
+			continue
+		}
+		for _, tug := range job.Tugs {
+			if tug == a || tug == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// searchMeters returns how far away another vessel's latest position can be and still
+// be in formation with the position: escortMeters, plus as far as the other can have
+// moved since its report. Being in formation, its speed is close to this one's
+func (d *TugJobDetector) searchMeters(position Positionable) float64 {
+	speed := float64(position.GetPositionReport().Speed)
+	if speed >= 102.3 {
+		return escortMeters
+	}
+	return escortMeters + (speed+escortSpeedToleranceKts)*metersPerSecPerKt*escortMaxReportAge.Seconds()
+}
+
+// evaluate checks a new position from history's vessel against the latest positions of
+// others, if it's a tug or a vessel tugs escort. others need only be those within
+// searchMeters of the position
+func (d *TugJobDetector) evaluate(position Positionable, history *ShipHistory, others []*ShipHistory) {
+	isTug, isEscortable := escortRoles(history.VoyageData())
+	if isTug == false && isEscortable == false {
+		return
+	}
+
+	for _, other := range others {
+		if other.MMSI == history.MMSI {
+			continue
+		}
+
+		otherIsTug, otherIsEscortable := escortRoles(other.VoyageData())
+		otherPositions := other.Positions()
+		if len(otherPositions) == 0 {
+			continue
+		}
+		otherPosition := otherPositions[len(otherPositions)-1]
+
+		switch {
+		case isTug && otherIsEscortable:
+			d.update(history.MMSI, other, position, otherPosition)
+		case isEscortable && otherIsTug:
+			d.update(other.MMSI, history, position, otherPosition)
+		}
+	}
+}
+
+// update records whether the tug is in formation with the vessel, starting or adding to
+// a tug job if it has been for long enough
+func (d *TugJobDetector) update(tug uint32, vessel *ShipHistory, latest, other Positionable) {
+	if inFormation(latest, other) == false {
+		return
+	}
+
+	at := latest.ReceivedTime()
+	key := escortKey{tug, vessel.MMSI}
+
+	d.Lock()
+	defer d.Unlock()
+
+	pair, ok := d.pairs[key]
+	if ok == false || at.Sub(pair.last) > escortLapse {
+		pair = &escortPair{since: at}
+		d.pairs[key] = pair
+	}
+	if at.After(pair.last) {
+		pair.last = at
+	}
+	if pair.last.Sub(pair.since) < escortConfirm {
+		return
+	}
+
+	job, ok := d.active[vessel.MMSI]
+	if ok == false {
+		d.lastID++
+		job = &TugJob{ID: d.lastID, Vessel: vessel.MMSI, Tugs: []uint32{tug}, Start: pair.since}
+		if vd := vessel.VoyageData(); vd != nil {
+			job.VesselName = vd.VesselName
+		}
+		d.active[vessel.MMSI] = job
+		d.subscribers.publish(TugJobEvent{TugJobStarted, job.copy()})
+		return
+	}
+
+	for _, t := range job.Tugs {
+		if t == tug {
+			return
+		}
+	}
+	job.Tugs = append(job.Tugs, tug)
+	d.subscribers.publish(TugJobEvent{TugJoined, job.copy()})
+}
+
+// expire ends the tug jobs none of whose tugs have been in formation since escortLapse
+// before now. Each ends when its last tug was last seen in formation
+func (d *TugJobDetector) expire(now time.Time) {
+	d.Lock()
+	defer d.Unlock()
+
+	for vessel, job := range d.active {
+		last := job.Start
+		for _, tug := range job.Tugs {
+			if pair, ok := d.pairs[escortKey{tug, vessel}]; ok && pair.last.After(last) {
+				last = pair.last
+			}
+		}
+		if now.Sub(last) <= escortLapse {
+			continue
+		}
+
+		job.End = last
+		delete(d.active, vessel)
+
+		d.log = append(d.log, *job)
+		if len(d.log) > d.LogSize {
+			d.log = d.log[len(d.log)-d.LogSize:]
+		}
+		d.subscribers.publish(TugJobEvent{TugJobEnded, job.copy()})
+	}
+
+	for key, pair := range d.pairs {
+		if now.Sub(pair.last) > escortLapse {
+			delete(d.pairs, key)
+		}
+	}
+}
+
+// forget drops the formations of a vessel that's no longer being tracked. Any job it's
+// part of ends at the next expire
+func (d *TugJobDetector) forget(mmsi uint32) {
+	d.Lock()
+	defer d.Unlock()
+
+	for key := range d.pairs {
+		if key.tug == mmsi || key.vessel == mmsi {
+			delete(d.pairs, key)
+		}
+	}
+}
+
+// escortRoles returns whether the vessel is a tug, and whether it's a cargo vessel or
+// tanker large enough to need one
+func escortRoles(vd *SourcedStaticVoyageData) (isTug, isEscortable bool) {
+	if vd == nil {
+		return false, false
+	}
+	length := int(vd.ToBow) + int(vd.ToStern)
+	return vd.ShipType == tugShipType, vd.ShipType >= 70 && vd.ShipType <= 89 && length >= minEscortedLengthMeters
+}
+
+// inFormation returns true if both vessels are under way at similar speeds and close to
+// each other, with the earlier position dead reckoned to the time of the later one
+func inFormation(a, b Positionable) bool {
+	if b.ReceivedTime().After(a.ReceivedTime()) {
+		a, b = b, a
+	}
+	if a.ReceivedTime().Sub(b.ReceivedTime()) > escortMaxReportAge {
+		return false
+	}
+
+	pa, pb := a.GetPositionReport(), b.GetPositionReport()
+	if pa.Speed < escortMinSpeedKts || pb.Speed < escortMinSpeedKts || pa.Speed >= 102.3 || pb.Speed >= 102.3 {
+		return false
+	}
+	if pa.Speed-pb.Speed > escortSpeedToleranceKts || pb.Speed-pa.Speed > escortSpeedToleranceKts {
+		return false
+	}
+
+	lat, lon, _ := DeadReckon(b, a.ReceivedTime(), escortMaxReportAge)
+	return distanceMeters(pa.Lat, pa.Lon, lat, lon) <= escortMeters
+}
@@ -0,0 +1,55 @@
+package shipdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andmarios/aislib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTugJob(t *testing.T) {
+	aisData := NewAISData()
+	aisData.TugJobs = NewTugJobDetector()
+	events := aisData.TugJobs.Subscribe(10)
+
+	aisData.UpdateStaticVoyageData(&SourcedStaticVoyageData{aislib.StaticVoyageData{MMSI: 1, VesselName: "GLOBAL TANKER", ShipType: 80, ToBow: 150, ToStern: 30}, SourceAndTime{}})
+	aisData.UpdateStaticVoyageData(&SourcedStaticVoyageData{aislib.StaticVoyageData{MMSI: 2, VesselName: "TUG ONE", ShipType: 52, ToBow: 20, ToStern: 10}, SourceAndTime{}})
+	aisData.UpdateStaticVoyageData(&SourcedStaticVoyageData{aislib.StaticVoyageData{MMSI: 3, VesselName: "TUG TWO", ShipType: 52, ToBow: 20, ToStern: 10}, SourceAndTime{}})
+
+	position := func(mmsi uint32, lat, lon float64, at time.Time) *SourcedClassAPositionReport {
+		return &SourcedClassAPositionReport{
+			aislib.ClassAPositionReport{PositionReport: aislib.PositionReport{MMSI: mmsi, Lat: lat, Lon: lon, Speed: 6, Course: 0}},
+			SourceAndTime{receivedTime: at},
+		}
+	}
+
+	// six knots north is 0.0008 degrees every 30 seconds. the first tug is 100m off the
+	// tanker, the second more than a kilometer away
+	start := time.Now().Add(-10 * time.Minute)
+	for i := 0; i <= 6; i++ {
+		at := start.Add(time.Duration(i) * 30 * time.Second)
+		lat := 41.77 + float64(i)*0.0008
+		aisData.AddPosition(position(1, lat, -71.38, at))
+		aisData.AddPosition(position(2, lat+0.0009, -71.38, at.Add(time.Second)))
+		aisData.AddPosition(position(3, lat, -71.365, at.Add(2*time.Second)))
+	}
+
+	jobs := aisData.TugJobs.Recent()
+	assert.Equal(t, 1, len(jobs))
+	assert.True(t, jobs[0].Active())
+	assert.Equal(t, uint32(1), jobs[0].Vessel)
+	assert.Equal(t, "GLOBAL TANKER", jobs[0].VesselName)
+	assert.Equal(t, []uint32{2}, jobs[0].Tugs)
+	assert.Equal(t, 2, len(aisData.TugJobs.Participants()))
+
+	// nothing in formation for more than the lapse ends the job
+	aisData.TugJobs.expire(time.Now())
+	jobs = aisData.TugJobs.Recent()
+	assert.Equal(t, 1, len(jobs))
+	assert.False(t, jobs[0].Active())
+	assert.Equal(t, start.Add(3*time.Minute+time.Second), jobs[0].End)
+
+	assert.Equal(t, TugJobStarted, (<-events).Type)
+	assert.Equal(t, TugJobEnded, (<-events).Type)
+}
@@ -0,0 +1,176 @@
+package views
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/andmarios/aislib"
+	"github.com/joemadeus/tugsy/tugsy/shipdata"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	atonDestSpriteSizePixels = 16
+
+	infoTextX, infoTextY = infoPaneDstX + 10, infoPaneDstY + 10
+)
+
+// AtoNInfoElement renders the details of an aid to navigation into a BaseInfoElement
+type AtoNInfoElement struct {
+	fonts *FontSet
+	aton  *shipdata.SourcedAidToNavigationReport
+}
+
+func NewAtoNInfoElement(fonts *FontSet, aton *shipdata.SourcedAidToNavigationReport) *AtoNInfoElement {
+	return &AtoNInfoElement{fonts: fonts, aton: aton}
+}
+
+func (e *AtoNInfoElement) ClosestChild(x, y int32) (ChildElement, float64) {
+	return nil, math.MaxFloat64
+}
+
+func (e *AtoNInfoElement) Render(v *View) error {
+	lines := []string{
+		e.aton.Name,
+		e.aton.AidTypeName(),
+		fmt.Sprintf("MMSI %d", e.aton.MMSI),
+		fmt.Sprintf("%.5f, %.5f", e.aton.Lat, e.aton.Lon),
+	}
+
+	if e.aton.Virtual {
+		lines = append(lines, "Virtual aid")
+	}
+	if e.aton.OffPosition {
+		lines = append(lines, "OFF POSITION")
+	}
+
+	return e.fonts.RenderLines(v, InfoTextColor, infoTextX, infoTextY, lines...)
+}
+
+// AllAtoNElements renders every known aid to navigation
+type AllAtoNElements struct {
+	sync.Mutex
+	*SpriteSet
+
+	fonts           *FontSet
+	aisData         *shipdata.AISData
+	atonElements    map[uint32]*AtoNElement
+	baseInfoElement *BaseInfoElement
+}
+
+func NewAllAtoNElements(sprites *SpriteSet, fonts *FontSet, ais *shipdata.AISData, be *BaseInfoElement) *AllAtoNElements {
+	return &AllAtoNElements{
+		SpriteSet:       sprites,
+		fonts:           fonts,
+		aisData:         ais,
+		atonElements:    make(map[uint32]*AtoNElement),
+		baseInfoElement: be,
+	}
+}
+
+func (e *AllAtoNElements) ClosestChild(x, y int32) (ChildElement, float64) {
+	e.Lock()
+	defer e.Unlock()
+
+	closest := struct {
+		ele *AtoNElement
+		d   float64
+	}{d: math.MaxFloat64}
+	for _, ae := range e.atonElements {
+		d := ae.Distance(x, y)
+		if d > closest.d {
+			continue
+		}
+
+		closest.d = d
+		closest.ele = ae
+	}
+
+	if closest.ele == nil {
+		return nil, math.MaxFloat64
+	}
+
+	logger.Debugf("AllAtoNElements ClosestChild at %s, %f", reflect.TypeOf(closest.ele).String(), closest.d)
+	return closest.ele, closest.d
+}
+
+func (e *AllAtoNElements) Render(v *View) error {
+	mmsis := make(map[uint32]struct{})
+	atons := e.aisData.AidsToNavigation() // returns a copy
+
+	e.Lock()
+	defer e.Unlock()
+
+	for _, aton := range atons {
+		ae, ok := e.atonElements[aton.MMSI]
+		if ok == false {
+			ae = &AtoNElement{SpriteSet: e.SpriteSet, fonts: e.fonts, baseInfoElement: e.baseInfoElement}
+			e.atonElements[aton.MMSI] = ae
+		}
+
+		// the registry replaces reports rather than updating them, so always take the latest
+		ae.aton = aton
+		if err := ae.Render(v); err != nil {
+			return err
+		}
+
+		mmsis[aton.MMSI] = struct{}{}
+	}
+
+	for m := range e.atonElements {
+		if _, ok := mmsis[m]; ok == false {
+			delete(e.atonElements, m)
+		}
+	}
+
+	return nil
+}
+
+// AtoNElement draws a single aid to navigation, with different sprites for virtual aids
+// and for floating aids that have drifted off position
+type AtoNElement struct {
+	*SpriteSet
+
+	fonts           *FontSet
+	curPosition     BaseMapPosition
+	aton            *shipdata.SourcedAidToNavigationReport
+	baseInfoElement *BaseInfoElement
+}
+
+func (e *AtoNElement) Distance(x, y int32) float64 {
+	d := screenDistance(x, y, e.curPosition.X, e.curPosition.Y)
+	logger.Debugf("AtoNElement distance is %f", d)
+	return d
+}
+
+func (e *AtoNElement) HandleTouch() error {
+	logger.Debug("Handling touch in AtoNElement")
+	return e.baseInfoElement.UpdateContent(NewAtoNInfoElement(e.fonts, e.aton))
+}
+
+func (e *AtoNElement) Render(v *View) error {
+	e.curPosition = v.BaseMapPosition(&aislib.PositionReport{Lat: e.aton.Lat, Lon: e.aton.Lon})
+
+	name := "aton"
+	switch {
+	case e.aton.OffPosition:
+		name = "aton_off_position"
+	case e.aton.Virtual:
+		name = "aton_virtual"
+	}
+
+	sprite, err := e.SpecialSheet.GetSprite(name)
+	if err != nil {
+		logger.WithError(err).Errorf("could not load special sprite '%s'", name)
+		return err
+	}
+
+	if err := v.ScreenRenderer.Copy(sprite.Texture, sprite.Rect, toDestRect(&e.curPosition, atonDestSpriteSizePixels)); err != nil {
+		logger.WithError(err).Error("rendering aid to navigation")
+		return err
+	}
+
+	return nil
+}
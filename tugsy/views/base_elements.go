@@ -55,19 +55,19 @@ type ChildElement interface {
 	Distance(x, y int32) float64
 }
 
+// The RootElement holds all the top level ParentElements, rendering them in the order
+// they were given
 type RootElement struct {
-	baseInfoElement     ParentElement
-	allPositionsElement ParentElement
+	children []ParentElement
 	// wxElement           *WxElement
 
 	touchFluff float64
 }
 
-func NewRootElement(cfg *config.Config, baseEle, allPosEle ParentElement) *RootElement {
+func NewRootElement(cfg *config.Config, children ...ParentElement) *RootElement {
 	return &RootElement{
-		touchFluff:          cfg.GetFloat64("touchFluff"),
-		baseInfoElement:     baseEle,
-		allPositionsElement: allPosEle,
+		touchFluff: cfg.GetFloat64("touchFluff"),
+		children:   children,
 	}
 }
 
@@ -77,7 +77,7 @@ func (e *RootElement) ClosestChild(x, y int32) (ChildElement, float64) {
 		d   float64
 	}{d: math.MaxFloat64}
 
-	for _, ele := range []ParentElement{e.baseInfoElement, e.allPositionsElement} {
+	for _, ele := range e.children {
 		e, d := ele.ClosestChild(x, y)
 		if d > closest.d {
 			continue
@@ -91,7 +91,7 @@ func (e *RootElement) ClosestChild(x, y int32) (ChildElement, float64) {
 }
 
 func (e *RootElement) Render(v *View) error {
-	for _, ele := range []UIElement{e.baseInfoElement, e.allPositionsElement} {
+	for _, ele := range e.children {
 		if err := ele.Render(v); err != nil {
 			return err
 		}
@@ -116,12 +116,14 @@ func (e *RootElement) Touch(x, y int32) error {
 // A BaseInfoElement is the "info pane" in the upper right part of the UI. It has a
 // two child UIElements: 'content', which displays the actual info (this type is
 // simply the frame in which that content is displayed) and 'close', which removes
-// the content and disables the info pane's rendering.
+// the content and either returns to the default content or, if there's none,
+// disables the info pane's rendering.
 type BaseInfoElement struct {
 	sync.Mutex
 
-	content ParentElement
-	close   ChildElement
+	content        ParentElement
+	defaultContent ParentElement
+	close          ChildElement
 
 	background *sdl.Texture
 	border     *sdl.Texture
@@ -160,7 +162,11 @@ func (e *BaseInfoElement) ClosestChild(x, y int32) (ChildElement, float64) {
 	defer e.Unlock()
 
 	if e.content == nil {
-		return nil, math.MaxFloat64
+		if e.defaultContent == nil {
+			return nil, math.MaxFloat64
+		}
+		// the default content can't be closed
+		return e.defaultContent.ClosestChild(x, y)
 	}
 
 	// TODO: shortcut... test that the point specified is within the BaseInfoElement's
@@ -181,8 +187,13 @@ func (e *BaseInfoElement) Render(v *View) error {
 	e.Lock()
 	defer e.Unlock()
 
+	content := e.content
+	if content == nil {
+		content = e.defaultContent
+	}
+
 	// don't bother showing the info element if there's no content
-	if e.content == nil {
+	if content == nil {
 		return nil
 	}
 
@@ -192,7 +203,7 @@ func (e *BaseInfoElement) Render(v *View) error {
 		return err
 	}
 
-	if err := e.content.Render(v); err != nil {
+	if err := content.Render(v); err != nil {
 		return err
 	}
 
@@ -217,6 +228,13 @@ func (e *BaseInfoElement) UpdateContent(c ParentElement) error {
 	return nil
 }
 
+// SetDefaultContent sets what the info pane shows when there's no other content
+func (e *BaseInfoElement) SetDefaultContent(c ParentElement) {
+	e.Lock()
+	defer e.Unlock()
+	e.defaultContent = c
+}
+
 // A CloseElement responds to user input by disabling info pane rendering
 type CloseElement struct {
 	closedElement *BaseInfoElement
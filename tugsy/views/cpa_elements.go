@@ -0,0 +1,102 @@
+package views
+
+import (
+	"math"
+	"time"
+
+	"github.com/andmarios/aislib"
+	"github.com/joemadeus/tugsy/tugsy/shipdata"
+	logger "github.com/sirupsen/logrus"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	cpaPointPixels = 6
+	cpaProjection  = 2 * time.Minute
+)
+
+var (
+	closeQuartersColor = sdl.Color{R: 255, G: 200, B: 0, A: 255}
+)
+
+// CloseQuartersElement draws every active close quarters situation: a line between the
+// two ships, and from each ship to where it will be at the closest point of approach.
+// It isn't touchable
+type CloseQuartersElement struct {
+	aisData *shipdata.AISData
+}
+
+func NewCloseQuartersElement(ais *shipdata.AISData) *CloseQuartersElement {
+	return &CloseQuartersElement{aisData: ais}
+}
+
+func (e *CloseQuartersElement) ClosestChild(x, y int32) (ChildElement, float64) {
+	return nil, math.MaxFloat64
+}
+
+func (e *CloseQuartersElement) Render(v *View) error {
+	if e.aisData.CloseQuarters == nil {
+		return nil
+	}
+
+	encounters := e.aisData.CloseQuarters.Encounters() // returns a copy
+	if len(encounters) == 0 {
+		return nil
+	}
+
+	c := closeQuartersColor
+	if err := v.ScreenRenderer.SetDrawColor(c.R, c.G, c.B, c.A); err != nil {
+		logger.WithError(err).Warn("setting the draw color")
+		return err
+	}
+
+	now := e.aisData.Now()
+	for _, encounter := range encounters {
+		a, okA := e.projectedPoint(v, encounter.A, now)
+		b, okB := e.projectedPoint(v, encounter.B, now)
+		if okA == false || okB == false {
+			continue
+		}
+
+		cpaA := toScreenPoint(v, encounter.Approach.ALat, encounter.Approach.ALon)
+		cpaB := toScreenPoint(v, encounter.Approach.BLat, encounter.Approach.BLon)
+		for _, line := range [][2]sdl.Point{{a, b}, {a, cpaA}, {b, cpaB}} {
+			if err := v.ScreenRenderer.DrawLine(line[0].X, line[0].Y, line[1].X, line[1].Y); err != nil {
+				logger.WithError(err).Warn("rendering a close quarters line")
+				return err
+			}
+		}
+
+		for _, p := range []sdl.Point{cpaA, cpaB} {
+			rect := &sdl.Rect{X: p.X - cpaPointPixels/2, Y: p.Y - cpaPointPixels/2, W: cpaPointPixels, H: cpaPointPixels}
+			if err := v.ScreenRenderer.FillRect(rect); err != nil {
+				logger.WithError(err).Warn("rendering a CPA point")
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// projectedPoint returns the screen point of the ship's dead reckoned position, or false
+// if the ship is gone
+func (e *CloseQuartersElement) projectedPoint(v *View, mmsi uint32, now time.Time) (sdl.Point, bool) {
+	history, ok := e.aisData.ShipHistory(mmsi)
+	if ok == false {
+		return sdl.Point{}, false
+	}
+
+	positions := history.Positions()
+	if len(positions) == 0 {
+		return sdl.Point{}, false
+	}
+
+	lat, lon, _ := shipdata.DeadReckon(positions[len(positions)-1], now, cpaProjection)
+	return toScreenPoint(v, lat, lon), true
+}
+
+func toScreenPoint(v *View, lat, lon float64) sdl.Point {
+	pos := v.BaseMapPosition(&aislib.PositionReport{Lat: lat, Lon: lon})
+	return sdl.Point{X: int32(pos.X + 0.5), Y: int32(pos.Y + 0.5)}
+}
@@ -1,15 +1,88 @@
 package views
 
+import (
+	"fmt"
+	"math"
+
+	"github.com/joemadeus/tugsy/tugsy/shipdata"
+)
+
+const (
+	portCallLines = 5
+	tugJobLines   = 3
+)
+
 type PortInfoElement struct {
 	CurrentPort string
+	PortCode    string
+
+	fonts     *FontSet
+	portCalls *shipdata.PortCallDetector
+	tugJobs   *shipdata.TugJobDetector
+}
+
+func NewPortInfoElement(fonts *FontSet, port, code string, portCalls *shipdata.PortCallDetector, tugJobs *shipdata.TugJobDetector) *PortInfoElement {
+	return &PortInfoElement{CurrentPort: port, PortCode: code, fonts: fonts, portCalls: portCalls, tugJobs: tugJobs}
 }
 
-func NewPortInfoElement(port string) *PortInfoElement {
-	return &PortInfoElement{CurrentPort: port}
+func (e *PortInfoElement) ClosestChild(x, y int32) (ChildElement, float64) {
+	return nil, math.MaxFloat64
 }
 
-// PortInfoElement renders the port name, its code, and a single list of N arrivals and
-// departures into the info pane. This is the default display for the info pane
-func (style *PortInfoElement) Render(view *View) error {
-	return nil
+// PortInfoElement renders the port name, its code, a single list of N arrivals and
+// departures and the latest tug jobs into the info pane. This is the default display for
+// the info pane
+func (e *PortInfoElement) Render(v *View) error {
+	title := e.CurrentPort
+	if e.PortCode != "" {
+		title += " (" + e.PortCode + ")"
+	}
+	lines := []string{title}
+
+	calls := e.portCalls.Recent()
+	if len(calls) == 0 {
+		lines = append(lines, "No arrivals or departures yet")
+	}
+	if len(calls) > portCallLines {
+		calls = calls[:portCallLines]
+	}
+
+	for _, call := range calls {
+		name := call.VesselName
+		if name == "" {
+			name = fmt.Sprintf("MMSI %d", call.MMSI)
+		}
+		line := fmt.Sprintf("%s %s %s", call.At.Local().Format("15:04"), call.Type, name)
+
+		// arrivals show where they came from, departures where they're bound
+		origin, port := call.Destination.Origin, call.Destination.Port
+		if call.Type == shipdata.Arrival && origin.Resolved() {
+			line += " from " + origin.Name
+		} else if call.Type == shipdata.Departure && port.Resolved() {
+			line += " for " + port.Name
+		}
+		lines = append(lines, line)
+	}
+
+	jobs := e.tugJobs.Recent()
+	if len(jobs) > 0 {
+		lines = append(lines, "Tug jobs")
+	}
+	if len(jobs) > tugJobLines {
+		jobs = jobs[:tugJobLines]
+	}
+
+	for _, job := range jobs {
+		name := job.VesselName
+		if name == "" {
+			name = fmt.Sprintf("MMSI %d", job.Vessel)
+		}
+		when := job.Start.Local().Format("15:04") + "-"
+		if job.Active() == false {
+			when += job.End.Local().Format("15:04")
+		}
+		lines = append(lines, fmt.Sprintf("%s %s, %d tug(s)", when, name, len(job.Tugs)))
+	}
+
+	return e.fonts.RenderLines(v, InfoTextColor, infoTextX, infoTextY, lines...)
 }
@@ -0,0 +1,248 @@
+package views
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/joemadeus/tugsy/tugsy/shipdata"
+	logger "github.com/sirupsen/logrus"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	alertBannerH           = 24
+	alertTextX, alertTextY = 8, 4
+
+	sarDestSpriteSizePixels = 24
+)
+
+var (
+	alertBannerColor = sdl.Color{R: 200, G: 30, B: 20, A: 255}
+	AlertTextColor   = sdl.Color{R: 255, G: 255, B: 255, A: 255}
+)
+
+// An AlertBannerElement shows the newest safety message across the top of the screen.
+// Touching the banner dismisses that message and shows the next, if there is one
+type AlertBannerElement struct {
+	sync.Mutex
+
+	fonts   *FontSet
+	aisData *shipdata.AISData
+	shown   *shipdata.Alert
+}
+
+func NewAlertBannerElement(fonts *FontSet, ais *shipdata.AISData) *AlertBannerElement {
+	return &AlertBannerElement{fonts: fonts, aisData: ais}
+}
+
+func (e *AlertBannerElement) ClosestChild(x, y int32) (ChildElement, float64) {
+	return e, e.Distance(x, y)
+}
+
+// Distance is zero anywhere on the banner, so that touching the banner always dismisses
+// the alert, even if there's a ship under it
+func (e *AlertBannerElement) Distance(x, y int32) float64 {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.shown == nil || y > alertBannerH {
+		return math.MaxFloat64
+	}
+	return 0
+}
+
+func (e *AlertBannerElement) HandleTouch() error {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.shown == nil {
+		return nil
+	}
+
+	logger.Debugf("Dismissing alert %d", e.shown.ID)
+	e.aisData.DismissAlert(e.shown.ID)
+	e.shown = nil
+	return nil
+}
+
+func (e *AlertBannerElement) Render(v *View) error {
+	alerts := e.aisData.Alerts() // newest first
+
+	e.Lock()
+	defer e.Unlock()
+
+	if len(alerts) == 0 {
+		e.shown = nil
+		return nil
+	}
+	e.shown = &alerts[0]
+
+	c := alertBannerColor
+	if err := v.ScreenRenderer.SetDrawColor(c.R, c.G, c.B, c.A); err != nil {
+		logger.WithError(err).Warn("setting the draw color")
+		return err
+	}
+
+	if err := v.ScreenRenderer.FillRect(&sdl.Rect{X: 0, Y: 0, W: ScreenWidth, H: alertBannerH}); err != nil {
+		logger.WithError(err).Warn("rendering alert banner")
+		return err
+	}
+
+	text := fmt.Sprintf("%d: %s", e.shown.Message.MMSI, e.shown.Message.Text)
+	if len(alerts) > 1 {
+		text += fmt.Sprintf(" (+%d)", len(alerts)-1)
+	}
+
+	return e.fonts.RenderText(v, text, AlertTextColor, alertTextX, alertTextY)
+}
+
+// SARAircraftInfoElement renders the details of a SAR aircraft into a BaseInfoElement
+type SARAircraftInfoElement struct {
+	fonts    *FontSet
+	aircraft *shipdata.SourcedSARAircraftReport
+}
+
+func NewSARAircraftInfoElement(fonts *FontSet, aircraft *shipdata.SourcedSARAircraftReport) *SARAircraftInfoElement {
+	return &SARAircraftInfoElement{fonts: fonts, aircraft: aircraft}
+}
+
+func (e *SARAircraftInfoElement) ClosestChild(x, y int32) (ChildElement, float64) {
+	return nil, math.MaxFloat64
+}
+
+func (e *SARAircraftInfoElement) Render(v *View) error {
+	lines := []string{
+		"SAR aircraft",
+		fmt.Sprintf("MMSI %d", e.aircraft.MMSI),
+		fmt.Sprintf("%.5f, %.5f", e.aircraft.Lat, e.aircraft.Lon),
+	}
+
+	if e.aircraft.Altitude < 4095 {
+		lines = append(lines, fmt.Sprintf("Altitude %d m", e.aircraft.Altitude))
+	}
+	if e.aircraft.Speed < 1023 {
+		lines = append(lines, fmt.Sprintf("%.0f kts, course %.0f", e.aircraft.Speed, e.aircraft.Course))
+	}
+
+	return e.fonts.RenderLines(v, InfoTextColor, infoTextX, infoTextY, lines...)
+}
+
+// AllSARAircraftElements renders every SAR aircraft that's reporting
+type AllSARAircraftElements struct {
+	sync.Mutex
+	*SpriteSet
+
+	fonts            *FontSet
+	aisData          *shipdata.AISData
+	aircraftElements map[uint32]*SARAircraftElement
+	baseInfoElement  *BaseInfoElement
+}
+
+func NewAllSARAircraftElements(sprites *SpriteSet, fonts *FontSet, ais *shipdata.AISData, be *BaseInfoElement) *AllSARAircraftElements {
+	return &AllSARAircraftElements{
+		SpriteSet:        sprites,
+		fonts:            fonts,
+		aisData:          ais,
+		aircraftElements: make(map[uint32]*SARAircraftElement),
+		baseInfoElement:  be,
+	}
+}
+
+func (e *AllSARAircraftElements) ClosestChild(x, y int32) (ChildElement, float64) {
+	e.Lock()
+	defer e.Unlock()
+
+	closest := struct {
+		ele *SARAircraftElement
+		d   float64
+	}{d: math.MaxFloat64}
+	for _, ae := range e.aircraftElements {
+		d := ae.Distance(x, y)
+		if d > closest.d {
+			continue
+		}
+
+		closest.d = d
+		closest.ele = ae
+	}
+
+	if closest.ele == nil {
+		return nil, math.MaxFloat64
+	}
+
+	return closest.ele, closest.d
+}
+
+func (e *AllSARAircraftElements) Render(v *View) error {
+	mmsis := make(map[uint32]struct{})
+	aircraft := e.aisData.SARAircraft() // returns a copy
+
+	e.Lock()
+	defer e.Unlock()
+
+	for _, a := range aircraft {
+		ae, ok := e.aircraftElements[a.MMSI]
+		if ok == false {
+			ae = &SARAircraftElement{SpriteSet: e.SpriteSet, fonts: e.fonts, baseInfoElement: e.baseInfoElement}
+			e.aircraftElements[a.MMSI] = ae
+		}
+
+		ae.aircraft = a
+		if err := ae.Render(v); err != nil {
+			return err
+		}
+
+		mmsis[a.MMSI] = struct{}{}
+	}
+
+	for m := range e.aircraftElements {
+		if _, ok := mmsis[m]; ok == false {
+			delete(e.aircraftElements, m)
+		}
+	}
+
+	return nil
+}
+
+// SARAircraftElement draws a single SAR aircraft, pointed along its course
+type SARAircraftElement struct {
+	*SpriteSet
+
+	fonts           *FontSet
+	curPosition     BaseMapPosition
+	aircraft        *shipdata.SourcedSARAircraftReport
+	baseInfoElement *BaseInfoElement
+}
+
+func (e *SARAircraftElement) Distance(x, y int32) float64 {
+	return screenDistance(x, y, e.curPosition.X, e.curPosition.Y)
+}
+
+func (e *SARAircraftElement) HandleTouch() error {
+	logger.Debug("Handling touch in SARAircraftElement")
+	return e.baseInfoElement.UpdateContent(NewSARAircraftInfoElement(e.fonts, e.aircraft))
+}
+
+func (e *SARAircraftElement) Render(v *View) error {
+	e.curPosition = v.BaseMapPosition(e.aircraft.GetPositionReport())
+
+	sprite, err := e.SpecialSheet.GetSprite("sar_aircraft")
+	if err != nil {
+		logger.WithError(err).Error("could not load special sprite 'sar_aircraft'")
+		return err
+	}
+
+	var angle float64
+	if e.aircraft.Course < 360 {
+		angle = float64(e.aircraft.Course)
+	}
+
+	dst := toDestRect(&e.curPosition, sarDestSpriteSizePixels)
+	if err := v.ScreenRenderer.CopyEx(sprite.Texture, sprite.Rect, dst, angle, nil, sdl.FLIP_NONE); err != nil {
+		logger.WithError(err).Error("rendering SAR aircraft")
+		return err
+	}
+
+	return nil
+}
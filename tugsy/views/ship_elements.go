@@ -1,10 +1,15 @@
 package views
 
 import (
+	"fmt"
 	"math"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/andmarios/aislib"
+	"github.com/joemadeus/tugsy/tugsy/config"
 	"github.com/joemadeus/tugsy/tugsy/shipdata"
 	logger "github.com/sirupsen/logrus"
 	"github.com/veandco/go-sdl2/sdl"
@@ -12,6 +17,29 @@ import (
 
 const (
 	defaultDestSpriteSizePixels = 20
+
+	// ships are drawn where dead reckoning puts them, up to this long after their last
+	// report. when a new report arrives, the drawn position glides to it over blendDur
+	defaultDeadReckoningHorizon = 2 * time.Minute
+	blendDur                    = 1 * time.Second
+
+	tugJobHighlightPixels = defaultDestSpriteSizePixels + 6
+
+	// ships just off the map are still drawn, since their tracks and projections may be on
+	// it. this is a fraction of the map's size
+	viewCullingMargin = 0.1
+
+	// the number of ships nearest a touch whose drawn positions are checked, since ships
+	// are drawn ahead of where they were reported
+	touchCandidates = 4
+
+	// the ship's flag sits in the top right corner of the info pane
+	infoFlagSize         = 32
+	infoFlagX, infoFlagY = infoPaneDstX + infoPaneW - infoFlagSize - 10, infoPaneDstY + 10
+)
+
+var (
+	tugJobHighlightColor = sdl.Color{R: 255, G: 60, B: 40, A: 255}
 )
 
 // ShipInfoElement renders information for a ship, including its registration, flag,
@@ -20,11 +48,12 @@ const (
 type ShipInfoElement struct {
 	*SpriteSet
 
+	fonts   *FontSet
 	history *shipdata.ShipHistory
 }
 
-func NewShipInfoElement(sprites *SpriteSet, h *shipdata.ShipHistory) *ShipInfoElement {
-	return &ShipInfoElement{SpriteSet: sprites, history: h}
+func NewShipInfoElement(sprites *SpriteSet, fonts *FontSet, h *shipdata.ShipHistory) *ShipInfoElement {
+	return &ShipInfoElement{SpriteSet: sprites, fonts: fonts, history: h}
 }
 
 func (e *ShipInfoElement) ClosestChild(x, y int32) (ChildElement, float64) {
@@ -32,13 +61,48 @@ func (e *ShipInfoElement) ClosestChild(x, y int32) (ChildElement, float64) {
 }
 
 func (e *ShipInfoElement) Render(v *View) error {
-	tex, err := v.ScreenRenderer.CreateTexture(PixelFormat, sdl.TEXTUREACCESS_STATIC, 0, 0)
-	if err != nil {
+	mmsi := shipdata.ClassifyMMSI(e.history.MMSI)
+	lines := []string{fmt.Sprintf("MMSI %d", mmsi.MMSI)}
+	if mmsi.Country != "" {
+		lines[0] += ", " + mmsi.Country
+	}
+
+	if vd := e.history.VoyageData(); vd != nil {
+		if name := strings.TrimSpace(vd.VesselName); name != "" {
+			lines = append([]string{name}, lines...)
+		}
+		if dest := shipdata.NormalizeDestination(vd.Destination); dest.Port.Name != "" {
+			lines = append(lines, "Destination "+dest.String())
+		}
+	}
+
+	if err := e.fonts.RenderLines(v, InfoTextColor, infoTextX, infoTextY, lines...); err != nil {
+		return err
+	}
+
+	return e.renderFlag(v, mmsi.ISO)
+}
+
+// renderFlag draws the flag of the country with the given ISO code in the corner of the
+// info pane
+func (e *ShipInfoElement) renderFlag(v *View, iso string) error {
+	// stations that aren't allocated by country (SARTs, EPIRBs and the like) have no flag,
+	// and a few territories have no sprite of their own
+	if iso == "" {
+		return nil
+	}
+
+	flag, err := e.SpriteSet.FlagSheet.GetSprite(iso)
+	if err == UnknownSpriteErr {
+		logger.Debugf("no flag sprite for '%s'", iso)
+		return nil
+	} else if err != nil {
 		return err
 	}
 
-	flag, err := e.SpriteSet.FlagSheet.GetSprite("JO")
-	if err != nil {
+	dst := &sdl.Rect{X: infoFlagX, Y: infoFlagY, W: infoFlagSize, H: infoFlagSize}
+	if err := v.ScreenRenderer.Copy(flag.Texture, flag.Rect, dst); err != nil {
+		logger.WithError(err).Error("rendering a ship's flag")
 		return err
 	}
 
@@ -49,17 +113,66 @@ type AllPositionElements struct {
 	sync.Mutex
 	*SpriteSet
 
+	fonts            *FontSet
 	aisData          *shipdata.AISData
+	changes          <-chan shipdata.ChangeEvent
 	positionElements map[uint32]*ShipPositionElement
 	baseInfoElement  *BaseInfoElement
+	horizon          time.Duration
+	lastView         *View // the view last rendered, for mapping touches back to the map
 }
 
-func NewAllPositionElements(sprites *SpriteSet, ais *shipdata.AISData, be *BaseInfoElement) *AllPositionElements {
-	return &AllPositionElements{
+// NewAllPositionElements creates the element that draws every ship. The
+// deadReckoningHorizon config key sets how far ahead of their last reports ships are
+// projected; zero draws them at their reported positions
+func NewAllPositionElements(cfg *config.Config, sprites *SpriteSet, fonts *FontSet, ais *shipdata.AISData, be *BaseInfoElement) *AllPositionElements {
+	horizon := defaultDeadReckoningHorizon
+	if cfg.IsSet("deadReckoningHorizon") {
+		horizon = cfg.GetDuration("deadReckoningHorizon")
+	}
+
+	e := &AllPositionElements{
 		SpriteSet:        sprites,
+		fonts:            fonts,
 		aisData:          ais,
 		positionElements: make(map[uint32]*ShipPositionElement),
 		baseInfoElement:  be,
+		horizon:          horizon,
+	}
+
+	// subscribe before adding the ships already known, so none are missed in between
+	e.changes = ais.Subscribe(shipdata.ChangeTypes(shipdata.VesselAdded, shipdata.VesselPruned))
+	for _, sh := range ais.ShipHistories() {
+		e.addElement(sh)
+	}
+
+	return e
+}
+
+func (e *AllPositionElements) addElement(sh *shipdata.ShipHistory) {
+	e.positionElements[sh.MMSI] = &ShipPositionElement{SpriteSet: e.SpriteSet, fonts: e.fonts, history: sh, baseInfoElement: e.baseInfoElement, clock: e.aisData, horizon: e.horizon}
+}
+
+// applyChanges adds and removes ShipPositionElements as ships come and go. A ship's
+// history is looked up when the change is applied, rather than trusting the change's
+// order, in case the ship was pruned and came back in the meantime. Called with e locked
+func (e *AllPositionElements) applyChanges() {
+	for {
+		var change shipdata.ChangeEvent
+		select {
+		case change = <-e.changes:
+		default:
+			return
+		}
+
+		sh, ok := e.aisData.ShipHistory(change.MMSI)
+		se, exists := e.positionElements[change.MMSI]
+		switch {
+		case ok == false:
+			delete(e.positionElements, change.MMSI)
+		case exists == false || se.history != sh:
+			e.addElement(sh)
+		}
 	}
 }
 
@@ -71,7 +184,17 @@ func (e *AllPositionElements) ClosestChild(x, y int32) (ChildElement, float64) {
 		ele *ShipPositionElement
 		d   float64
 	}{d: math.MaxFloat64}
-	for _, sp := range e.positionElements {
+	if e.lastView == nil {
+		return nil, closest.d
+	}
+
+	lat, lon := e.lastView.GeoPosition(x, y)
+	for _, sh := range e.aisData.NearestShips(lat, lon, touchCandidates) {
+		sp, ok := e.positionElements[sh.MMSI]
+		if ok == false {
+			continue
+		}
+
 		d := sp.Distance(x, y)
 		if d > closest.d {
 			continue
@@ -86,32 +209,30 @@ func (e *AllPositionElements) ClosestChild(x, y int32) (ChildElement, float64) {
 }
 
 func (e *AllPositionElements) Render(v *View) error {
-	mmsis := make(map[uint32]struct{})
-	histories := e.aisData.ShipHistories() // returns a copy
+	var onTugJobs map[uint32]struct{}
+	if e.aisData.TugJobs != nil {
+		onTugJobs = e.aisData.TugJobs.Participants()
+	}
 
 	e.Lock()
 	defer e.Unlock()
 
-	for _, sh := range histories {
+	e.applyChanges()
+	e.lastView = v
+
+	for _, sh := range e.aisData.ShipsInBounds(viewBounds(v, viewCullingMargin)) {
+		// the change that added it may have been dropped while another view was showing
 		se, ok := e.positionElements[sh.MMSI]
-		if ok == false {
-			se = &ShipPositionElement{SpriteSet: e.SpriteSet, history: sh, baseInfoElement: e.baseInfoElement}
-			e.positionElements[sh.MMSI] = se
+		if ok == false || se.history != sh {
+			e.addElement(sh)
+			se = e.positionElements[sh.MMSI]
 		}
 
+		_, se.onTugJob = onTugJobs[sh.MMSI]
+
 		if err := se.Render(v); err != nil {
 			return err
 		}
-
-		mmsis[sh.MMSI] = struct{}{}
-	}
-
-	// prune ShipPositionElements that no longer exist. could be replaced, along
-	// with add(), with a chan, I suppose
-	for m := range e.positionElements {
-		if _, ok := mmsis[m]; ok == false {
-			delete(e.positionElements, m)
-		}
 	}
 
 	return nil
@@ -120,9 +241,20 @@ func (e *AllPositionElements) Render(v *View) error {
 type ShipPositionElement struct {
 	*SpriteSet
 
+	fonts           *FontSet
 	curPosition     BaseMapPosition
 	history         *shipdata.ShipHistory
 	baseInfoElement *BaseInfoElement
+	clock           shipdata.Clock // the AIS data's, which a replay runs faster than the wall clock
+	horizon         time.Duration
+	onTugJob        bool // the ship is a tug, or is being escorted by tugs
+
+	// the report the drawn position is projected from, where the ship was drawn when
+	// that report arrived, and when it arrived. drawn is where the ship was drawn last
+	lastReport shipdata.Positionable
+	blendFrom  aislib.PositionReport
+	blendStart time.Time
+	drawn      aislib.PositionReport
 }
 
 func (e *ShipPositionElement) Distance(x, y int32) float64 {
@@ -133,7 +265,7 @@ func (e *ShipPositionElement) Distance(x, y int32) float64 {
 
 func (e *ShipPositionElement) HandleTouch() error {
 	logger.Debug("Handling touch in ShipPositionElement")
-	infoElement := NewShipInfoElement(e.SpriteSet, e.history)
+	infoElement := NewShipInfoElement(e.SpriteSet, e.fonts, e.history)
 	return e.baseInfoElement.UpdateContent(infoElement)
 }
 
@@ -146,20 +278,47 @@ func (e *ShipPositionElement) Render(v *View) error {
 	// TODO we're reloading sprites and primitives every time through. cut that
 	//  out and start holding some view state
 
+	e.updateDrawnPosition(positions[len(positions)-1], e.clock.Now())
+
 	hue := shipTypeToHue(e.history)
 	if err := e.renderHistory(v, hue, positions); err != nil {
 		return err
 	}
 
-	if err := e.renderPosition(v, hue, positions); err != nil {
+	if err := e.renderPosition(v, hue); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (e *ShipPositionElement) renderPosition(view *View, hue Hue, positions []shipdata.Positionable) error {
-	e.curPosition = view.BaseMapPosition(positions[len(positions)-1].GetPositionReport())
+// updateDrawnPosition works out where to draw the ship at now: its dead reckoned
+// position, or on the way there from where it was drawn if latest is a new report
+func (e *ShipPositionElement) updateDrawnPosition(latest shipdata.Positionable, now time.Time) {
+	if latest != e.lastReport {
+		if e.lastReport == nil {
+			e.drawn = *latest.GetPositionReport()
+		}
+		e.lastReport = latest
+		e.blendFrom = e.drawn
+		e.blendStart = now
+	}
+
+	target := *latest.GetPositionReport()
+	if e.horizon > 0 {
+		target.Lat, target.Lon, _ = shipdata.DeadReckon(latest, now, e.horizon)
+	}
+
+	e.drawn = target
+	if blended := now.Sub(e.blendStart); blended < blendDur {
+		f := blended.Seconds() / blendDur.Seconds()
+		e.drawn.Lat = e.blendFrom.Lat + (target.Lat-e.blendFrom.Lat)*f
+		e.drawn.Lon = e.blendFrom.Lon + (target.Lon-e.blendFrom.Lon)*f
+	}
+}
+
+func (e *ShipPositionElement) renderPosition(view *View, hue Hue) error {
+	e.curPosition = view.BaseMapPosition(&e.drawn)
 
 	var sprite *Sprite
 	var err error
@@ -185,6 +344,18 @@ func (e *ShipPositionElement) renderPosition(view *View, hue Hue, positions []sh
 		return err
 	}
 
+	if e.onTugJob {
+		c := tugJobHighlightColor
+		if err := view.ScreenRenderer.SetDrawColor(c.R, c.G, c.B, c.A); err != nil {
+			logger.WithError(err).Warn("setting the draw color")
+			return err
+		}
+		if err := view.ScreenRenderer.DrawRect(toDestRect(&e.curPosition, tugJobHighlightPixels)); err != nil {
+			logger.WithError(err).Warn("rendering a tug job highlight")
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -193,7 +364,7 @@ func (e *ShipPositionElement) renderHistory(view *View, hue Hue, positions []shi
 	trackAlpha := uint8(128)
 
 	r, g, b := HueToRGB(hue)
-	sdlPoints := make([]sdl.Point, len(positions), len(positions))
+	sdlPoints := make([]sdl.Point, len(positions), len(positions)+1)
 	sdlRects := make([]sdl.Rect, len(positions), len(positions))
 
 	for i, position := range positions {
@@ -210,6 +381,10 @@ func (e *ShipPositionElement) renderHistory(view *View, hue Hue, positions []shi
 		}
 	}
 
+	// extend the track to where the ship is drawn, but without a track point there
+	drawn := view.BaseMapPosition(&e.drawn)
+	sdlPoints = append(sdlPoints, sdl.Point{X: int32(drawn.X + 0.5), Y: int32(drawn.Y + 0.5)})
+
 	if err := view.ScreenRenderer.SetDrawColor(r, g, b, trackAlpha); err != nil {
 		logger.WithError(err).Warn("setting the draw color")
 		return err
@@ -228,6 +403,18 @@ func (e *ShipPositionElement) renderHistory(view *View, hue Hue, positions []shi
 	return nil
 }
 
+// viewBounds returns the area covered by the view's base map, enlarged on every side by
+// margin times its size
+func viewBounds(v *View, margin float64) shipdata.BoundingBox {
+	dLat, dLon := (v.NEGeo.Y-v.SWGeo.Y)*margin, (v.NEGeo.X-v.SWGeo.X)*margin
+	return shipdata.BoundingBox{
+		South: v.SWGeo.Y - dLat,
+		West:  v.SWGeo.X - dLon,
+		North: v.NEGeo.Y + dLat,
+		East:  v.NEGeo.X + dLon,
+	}
+}
+
 func toDestRect(position *BaseMapPosition, pixSquare int32) *sdl.Rect {
 	return &sdl.Rect{
 		X: int32(position.X+0.5) - (pixSquare / 2),
@@ -303,295 +490,3 @@ func shipTypeToHue(history *shipdata.ShipHistory) Hue {
 	logger.WithField("type num", voyagedata.ShipType).Warn("mapping an unhandled ship type")
 	return 0
 }
-
-var MIDInformalNames = map[int]string{
-	// TODO could easily be config
-	201: "Albania",
-	202: "Andorra",
-	203: "Austria",
-	204: "Azores (PT)",
-	205: "Belgium",
-	206: "Belarus",
-	207: "Bulgaria",
-	208: "Vatican City State",
-	209: "Cyprus",
-	210: "Cyprus",
-	211: "Germany",
-	212: "Cyprus",
-	213: "Georgia",
-	214: "Moldova",
-	215: "Malta",
-	216: "Armenia",
-	218: "Germany",
-	219: "Denmark",
-	220: "Denmark",
-	224: "Spain",
-	225: "Spain",
-	226: "France",
-	227: "France",
-	228: "France",
-	229: "Malta",
-	230: "Finland",
-	231: "Faroe Islands (DK)",
-	232: "United Kingdom",
-	233: "United Kingdom",
-	234: "United Kingdom",
-	235: "United Kingdom",
-	236: "Gibraltar (UK)",
-	237: "Greece",
-	238: "Croatia",
-	239: "Greece",
-	240: "Greece",
-	241: "Greece",
-	242: "Morocco",
-	243: "Hungary",
-	244: "Netherlands",
-	245: "Netherlands",
-	246: "Netherlands",
-	247: "Italy",
-	248: "Malta",
-	249: "Malta",
-	250: "Ireland",
-	251: "Iceland",
-	252: "Liechtenstein",
-	253: "Luxembourg",
-	254: "Monaco",
-	255: "Madeira (PT)",
-	256: "Malta",
-	257: "Norway",
-	258: "Norway",
-	259: "Norway",
-	261: "Poland",
-	262: "Montenegro",
-	263: "Portugal",
-	264: "Romania",
-	265: "Sweden",
-	266: "Sweden",
-	267: "Slovak Republic",
-	268: "San Marino",
-	269: "Switzerland",
-	270: "Czech Republic",
-	271: "Turkey",
-	272: "Ukraine",
-	273: "Russian Federation",
-	274: "Macedonia",
-	275: "Latvia",
-	276: "Estonia",
-	277: "Lithuania",
-	278: "Slovenia",
-	279: "Serbia",
-	301: "Anguilla (UK)",
-	303: "Alaska (US)",
-	304: "Antigua & Barbuda",
-	305: "Antigua & Barbuda",
-	306: "NL Caribbean Islands",
-	307: "Aruba (NL)",
-	308: "Bahamas",
-	309: "Bahamas",
-	310: "Bermuda (UK)",
-	311: "Bahamas",
-	312: "Belize",
-	314: "Barbados",
-	316: "Canada",
-	319: "Cayman Islands (UK)",
-	321: "Costa Rica",
-	323: "Cuba",
-	325: "Dominica",
-	327: "Dominican Republic",
-	329: "Guadeloupe (FR)",
-	330: "Grenada",
-	331: "Greenland (DK)",
-	332: "Guatemala",
-	334: "Honduras",
-	336: "Haiti",
-	338: "United States",
-	339: "Jamaica",
-	341: "St. Kitts & Nevis",
-	343: "St. Lucia",
-	345: "Mexico",
-	347: "Martinique (FR)",
-	348: "Montserrat (UK)",
-	350: "Nicaragua",
-	351: "Panama",
-	352: "Panama",
-	353: "Panama",
-	354: "Panama",
-	355: " - ",
-	356: " - ",
-	357: " - ",
-	358: "Puerto Rico (US)",
-	359: "El Salvador",
-	361: "St. Pierre & Miquelon (FR)",
-	362: "Trinidad & Tobago",
-	364: "Turks & Caicos (UK)",
-	366: "United States",
-	367: "United States",
-	368: "United States",
-	369: "United States",
-	370: "Panama",
-	371: "Panama",
-	372: "Panama",
-	373: "Panama",
-	375: "St. Vincent & the Grenadines",
-	376: "St. Vincent & the Grenadines",
-	377: "St. Vincent & the Grenadines",
-	378: "Virgin Islands (UK)",
-	379: "Virgin Islands (US)",
-	401: "Afghanistan",
-	403: "Saudi Arabia",
-	405: "Bangladesh",
-	408: "Bahrain",
-	410: "Bhutan",
-	412: "China",
-	413: "China",
-	414: "China",
-	416: "Taiwan (CN)",
-	417: "Sri Lanka",
-	419: "India",
-	422: "Iran",
-	423: "Azerbaijan",
-	425: "Iraq",
-	428: "Israel",
-	431: "Japan",
-	432: "Japan",
-	434: "Turkmenistan",
-	436: "Kazakhstan",
-	437: "Uzbekistan",
-	438: "Jordan",
-	440: "Korea",
-	441: "Korea",
-	443: "State of Palestine",
-	445: "Korea (DPR)",
-	447: "Kuwait",
-	450: "Lebanon",
-	451: "Kyrgyz Republic",
-	453: "Macao (CN)",
-	455: "Maldives",
-	457: "Mongolia",
-	459: "Nepal",
-	461: "Oman",
-	463: "Pakistan",
-	466: "Qatar",
-	468: "Syria",
-	470: "United Arab Emirates",
-	472: "Tajikistan",
-	473: "Yemen",
-	475: "Yemen",
-	477: "Hong Kong (CN)",
-	478: "Bosnia & Herzegovina",
-	501: "Adelie Land (FR)",
-	503: "Australia",
-	506: "Myanmar",
-	508: "Brunei Darussalam",
-	510: "Micronesia",
-	511: "Palau",
-	512: "New Zealand",
-	514: "Cambodia",
-	515: "Cambodia",
-	516: "Christmas Island (AU)",
-	518: "Cook Islands (NZ)",
-	520: "Fiji",
-	523: "Cocos Islands (AU)",
-	525: "Indonesia",
-	529: "Kiribati",
-	531: "Laos",
-	533: "Malaysia",
-	536: "Northern Mariana Islands (US)",
-	538: "Marshall Islands",
-	540: "New Caledonia (FR)",
-	542: "Niue (NZ)",
-	544: "Nauru",
-	546: "French Polynesia (FR)",
-	548: "Philippines",
-	553: "Papua New Guinea",
-	555: "Pitcairn Island (UK)",
-	557: "Solomon Islands",
-	559: "American Samoa (US)",
-	561: "Samoa",
-	563: "Singapore",
-	564: "Singapore",
-	565: "Singapore",
-	566: "Singapore",
-	567: "Thailand",
-	570: "Tonga",
-	572: "Tuvalu",
-	574: "Viet Nam",
-	576: "Vanuatu",
-	577: "Vanuatu",
-	578: "Wallis & Futuna Islands (FR)",
-	601: "South Africa",
-	603: "Angola",
-	605: "Algeria",
-	607: "St. Paul, Amsterdam Islands (FR)",
-	608: "Ascension Island (UK)",
-	609: "Burundi",
-	610: "Benin",
-	611: "Botswana",
-	612: "Central African Republic",
-	613: "Cameroon",
-	615: "Congo",
-	616: "Comoros",
-	617: "Cabo Verde",
-	618: "Crozet Archipelago (FR)",
-	619: "Cote d'Ivoire",
-	620: "Comoros",
-	621: "Djibouti",
-	622: "Egypt",
-	624: "Ethiopia",
-	625: "Eritrea",
-	626: "Gabonese Republic",
-	627: "Ghana",
-	629: "Gambia",
-	630: "Guinea-Bissau",
-	631: "Equatorial Guinea",
-	632: "Guinea",
-	633: "Burkina Faso",
-	634: "Kenya",
-	635: "Kerguelen Islands (FR)",
-	636: "Liberia",
-	637: "Liberia",
-	638: "South Sudan",
-	642: "Libya",
-	644: "Lesotho",
-	645: "Mauritius",
-	647: "Madagascar",
-	649: "Mali",
-	650: "Mozambique",
-	654: "Mauritania",
-	655: "Malawi",
-	656: "Niger",
-	657: "Nigeria",
-	659: "Namibia",
-	660: "Reunion (FR)",
-	661: "Rwanda",
-	662: "Sudan",
-	663: "Senegal",
-	664: "Seychelles",
-	665: "St. Helena (UK)",
-	666: "Somalia",
-	667: "Sierra Leone",
-	668: "Sao Tome & Principe",
-	669: "Swaziland",
-	670: "Chad",
-	671: "Togolese Republic",
-	672: "Tunisia",
-	674: "Tanzania",
-	675: "Uganda",
-	676: "Congo",
-	677: "Tanzania",
-	678: "Zambia",
-	679: "Zimbabwe",
-	701: "Argentina",
-	710: "Brazil",
-	720: "Bolivia",
-	725: "Chile",
-	730: "Colombia",
-	735: "Ecuador",
-	740: "Falkland Islands (UK)",
-	745: "Guiana (FR)",
-	750: "Guyana",
-	755: "Paraguay",
-	760: "Peru",
-	765: "Suriname",
-	770: "Uruguay)",
-}
@@ -11,6 +11,7 @@ import (
 
 const (
 	defaultSpriteSizePixels = 40
+	specialSpriteSizePixels = 32 // see the 'special-sprites' target in the Makefile
 
 	dotsSpritesFile    = "dots-normal.png"
 	specialSpritesFile = "special.png"
@@ -141,7 +142,7 @@ func NewSpecialSheet(screenRenderer *sdl.Renderer, config *config.Config) (*Spec
 
 	special := &SpecialSheet{}
 	special.Texture = tex
-	special.SpriteSize = defaultSpriteSizePixels
+	special.SpriteSize = specialSpriteSizePixels
 
 	special.MarkerMap = make(map[string]int)
 	special.MarkerMap["unknown"] = 0
@@ -150,6 +151,10 @@ func NewSpecialSheet(screenRenderer *sdl.Renderer, config *config.Config) (*Spec
 	special.MarkerMap["hazard_c"] = 3
 	special.MarkerMap["hazard_d"] = 4
 	special.MarkerMap["red_ring"] = 5
+	special.MarkerMap["aton"] = 6
+	special.MarkerMap["aton_virtual"] = 7
+	special.MarkerMap["aton_off_position"] = 8
+	special.MarkerMap["sar_aircraft"] = 9
 
 	return special, nil
 }
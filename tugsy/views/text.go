@@ -1,4 +1,95 @@
 package views
 
+import (
+	"errors"
+
+	"github.com/joemadeus/tugsy/tugsy/config"
+	logger "github.com/sirupsen/logrus"
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+const (
+	defaultFontSize = 14
+)
+
+var (
+	NoFontConfigured = errors.New("no font configured")
+
+	InfoTextColor = sdl.Color{R: 32, G: 32, B: 32, A: 255}
+)
+
+// A FontSet renders text to the screen, in the font named by the 'font' key: a file in
+// the Resources/fonts directory. Every info panel is text, so there's no running without
+// one
 type FontSet struct {
+	font       *ttf.Font
+	lineHeight int32
+}
+
+func NewFontSet(cfg *config.Config) (*FontSet, error) {
+	fontFile := cfg.GetString("font")
+	if fontFile == "" {
+		return nil, NoFontConfigured
+	}
+
+	logger.Infof("Loading font %s", fontFile)
+	if err := ttf.Init(); err != nil {
+		return nil, err
+	}
+
+	size := cfg.GetInt("fontSize")
+	if size == 0 {
+		size = defaultFontSize
+	}
+
+	font, err := ttf.OpenFont(cfg.FontPath(fontFile), size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FontSet{font: font, lineHeight: int32(font.Height())}, nil
+}
+
+func (f *FontSet) Teardown() error {
+	if f.font != nil {
+		f.font.Close()
+		ttf.Quit()
+	}
+
+	return nil
+}
+
+// RenderLines renders each line of text one below the other, with the first line's top
+// left corner at x, y
+func (f *FontSet) RenderLines(v *View, color sdl.Color, x, y int32, lines ...string) error {
+	for _, line := range lines {
+		if err := f.RenderText(v, line, color, x, y); err != nil {
+			return err
+		}
+		y += f.lineHeight
+	}
+
+	return nil
+}
+
+// RenderText renders a single line of text with its top left corner at x, y
+func (f *FontSet) RenderText(v *View, text string, color sdl.Color, x, y int32) error {
+	if text == "" {
+		return nil
+	}
+
+	surface, err := f.font.RenderUTF8Blended(text, color)
+	if err != nil {
+		return err
+	}
+	defer surface.Free()
+
+	tex, err := v.ScreenRenderer.CreateTextureFromSurface(surface)
+	if err != nil {
+		return err
+	}
+	defer tex.Destroy()
+
+	return v.ScreenRenderer.Copy(tex, nil, &sdl.Rect{X: x, Y: y, W: surface.W, H: surface.H})
 }
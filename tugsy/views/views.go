@@ -147,6 +147,14 @@ func (v *View) BaseMapPosition(position *aislib.PositionReport) BaseMapPosition
 	}
 }
 
+// GeoPosition is the inverse of BaseMapPosition, returning the latitude and longitude
+// of a pixel on the view's base map
+func (v *View) GeoPosition(x, y int32) (lat, lon float64) {
+	lon = v.SWGeo.X + float64(x)/v.width*(v.NEGeo.X-v.SWGeo.X)
+	lat = v.SWGeo.Y + (v.height-float64(y))/v.height*(v.NEGeo.Y-v.SWGeo.Y)
+	return lat, lon
+}
+
 type position struct {
 	X float64
 	Y float64
@@ -1,5 +1,26 @@
 package views
 
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/andmarios/aislib"
+	"github.com/joemadeus/tugsy/tugsy/weatherdata"
+	logger "github.com/sirupsen/logrus"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	wxStationSizePixels = 6
+	wxWindPixelsPerKt   = 1.5
+	wxMaxWindKts        = 40
+)
+
+var (
+	wxStationColor = sdl.Color{R: 40, G: 80, B: 200, A: 255}
+)
+
 type TideBarElement struct{}
 
 // A TideBarElement renders the current position of the tide, whether it's advancing or
@@ -15,3 +36,186 @@ type WxButtonElement struct{}
 func (style *WxButtonElement) Render(view *View) error {
 	return nil
 }
+
+// WxStationInfoElement renders the latest readings from a weather station into a
+// BaseInfoElement
+type WxStationInfoElement struct {
+	fonts   *FontSet
+	station *weatherdata.StationReport
+}
+
+func NewWxStationInfoElement(fonts *FontSet, station *weatherdata.StationReport) *WxStationInfoElement {
+	return &WxStationInfoElement{fonts: fonts, station: station}
+}
+
+func (e *WxStationInfoElement) ClosestChild(x, y int32) (ChildElement, float64) {
+	return nil, math.MaxFloat64
+}
+
+func (e *WxStationInfoElement) Render(v *View) error {
+	s := e.station
+	lines := []string{
+		s.StationID,
+		fmt.Sprintf("Observed %s UTC", s.ObservedAt.UTC().Format("Jan 2 15:04")),
+	}
+
+	if weatherdata.Available(s.WindSpeedKts) {
+		wind := fmt.Sprintf("Wind %.0f kts", s.WindSpeedKts)
+		if weatherdata.Available(s.WindDirection) {
+			wind += fmt.Sprintf(" from %03.0f", s.WindDirection)
+		}
+		if weatherdata.Available(s.WindGustKts) {
+			wind += fmt.Sprintf(", gusts %.0f", s.WindGustKts)
+		}
+		lines = append(lines, wind)
+	}
+	if weatherdata.Available(s.AirTempC) {
+		lines = append(lines, fmt.Sprintf("Air %.1f C", s.AirTempC))
+	}
+	if weatherdata.Available(s.WaterTempC) {
+		lines = append(lines, fmt.Sprintf("Water %.1f C", s.WaterTempC))
+	}
+	if weatherdata.Available(s.PressureHPa) {
+		lines = append(lines, fmt.Sprintf("Pressure %.0f hPa", s.PressureHPa))
+	}
+	if weatherdata.Available(s.VisibilityNM) {
+		lines = append(lines, fmt.Sprintf("Visibility %.1f nm", s.VisibilityNM))
+	}
+	if weatherdata.Available(s.WaterLevelM) {
+		lines = append(lines, fmt.Sprintf("Water level %+.2f m", s.WaterLevelM))
+	}
+	if weatherdata.Available(s.CurrentSpeedKts) {
+		current := fmt.Sprintf("Current %.1f kts", s.CurrentSpeedKts)
+		if weatherdata.Available(s.CurrentDirection) {
+			current += fmt.Sprintf(" toward %03.0f", s.CurrentDirection)
+		}
+		lines = append(lines, current)
+	}
+
+	return e.fonts.RenderLines(v, InfoTextColor, infoTextX, infoTextY, lines...)
+}
+
+// AllWxStationElements renders every weather station that's reporting
+type AllWxStationElements struct {
+	sync.Mutex
+
+	fonts           *FontSet
+	wxData          *weatherdata.WeatherData
+	stationElements map[string]*WxStationElement
+	baseInfoElement *BaseInfoElement
+}
+
+func NewAllWxStationElements(fonts *FontSet, wx *weatherdata.WeatherData, be *BaseInfoElement) *AllWxStationElements {
+	return &AllWxStationElements{
+		fonts:           fonts,
+		wxData:          wx,
+		stationElements: make(map[string]*WxStationElement),
+		baseInfoElement: be,
+	}
+}
+
+func (e *AllWxStationElements) ClosestChild(x, y int32) (ChildElement, float64) {
+	e.Lock()
+	defer e.Unlock()
+
+	closest := struct {
+		ele *WxStationElement
+		d   float64
+	}{d: math.MaxFloat64}
+	for _, se := range e.stationElements {
+		d := se.Distance(x, y)
+		if d > closest.d {
+			continue
+		}
+
+		closest.d = d
+		closest.ele = se
+	}
+
+	if closest.ele == nil {
+		return nil, math.MaxFloat64
+	}
+
+	return closest.ele, closest.d
+}
+
+func (e *AllWxStationElements) Render(v *View) error {
+	ids := make(map[string]struct{})
+	stations := e.wxData.Stations() // returns a copy
+
+	e.Lock()
+	defer e.Unlock()
+
+	for _, station := range stations {
+		se, ok := e.stationElements[station.StationID]
+		if ok == false {
+			se = &WxStationElement{fonts: e.fonts, baseInfoElement: e.baseInfoElement}
+			e.stationElements[station.StationID] = se
+		}
+
+		se.station = station
+		if err := se.Render(v); err != nil {
+			return err
+		}
+
+		ids[station.StationID] = struct{}{}
+	}
+
+	for id := range e.stationElements {
+		if _, ok := ids[id]; ok == false {
+			delete(e.stationElements, id)
+		}
+	}
+
+	return nil
+}
+
+// WxStationElement draws a weather station as a small square, with a line pointing
+// downwind whose length is proportional to the wind speed
+type WxStationElement struct {
+	fonts           *FontSet
+	curPosition     BaseMapPosition
+	station         *weatherdata.StationReport
+	baseInfoElement *BaseInfoElement
+}
+
+func (e *WxStationElement) Distance(x, y int32) float64 {
+	return screenDistance(x, y, e.curPosition.X, e.curPosition.Y)
+}
+
+func (e *WxStationElement) HandleTouch() error {
+	logger.Debug("Handling touch in WxStationElement")
+	return e.baseInfoElement.UpdateContent(NewWxStationInfoElement(e.fonts, e.station))
+}
+
+func (e *WxStationElement) Render(v *View) error {
+	e.curPosition = v.BaseMapPosition(&aislib.PositionReport{Lat: e.station.Lat, Lon: e.station.Lon})
+
+	c := wxStationColor
+	if err := v.ScreenRenderer.SetDrawColor(c.R, c.G, c.B, c.A); err != nil {
+		logger.WithError(err).Warn("setting the draw color")
+		return err
+	}
+
+	if err := v.ScreenRenderer.FillRect(toDestRect(&e.curPosition, wxStationSizePixels)); err != nil {
+		logger.WithError(err).Warn("rendering weather station")
+		return err
+	}
+
+	if weatherdata.Available(e.station.WindSpeedKts) == false || weatherdata.Available(e.station.WindDirection) == false {
+		return nil
+	}
+
+	// the wind direction is where it's blowing from, so draw the line the other way
+	length := math.Min(e.station.WindSpeedKts, wxMaxWindKts) * wxWindPixelsPerKt
+	radians := (e.station.WindDirection + 180) * math.Pi / 180
+	x := e.curPosition.X + length*math.Sin(radians)
+	y := e.curPosition.Y - length*math.Cos(radians)
+
+	if err := v.ScreenRenderer.DrawLine(int32(e.curPosition.X+0.5), int32(e.curPosition.Y+0.5), int32(x+0.5), int32(y+0.5)); err != nil {
+		logger.WithError(err).Warn("rendering wind")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package views
+
+import (
+	"math"
+
+	"github.com/andmarios/aislib"
+	"github.com/joemadeus/tugsy/tugsy/shipdata"
+	logger "github.com/sirupsen/logrus"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var (
+	zoneColor = sdl.Color{R: 220, G: 220, B: 220, A: 160}
+)
+
+// ZoneOverlayElement outlines the geofence zones on the map, with their names. It's an
+// optional overlay, and isn't touchable
+type ZoneOverlayElement struct {
+	fonts     *FontSet
+	geofences *shipdata.Geofences
+}
+
+func NewZoneOverlayElement(fonts *FontSet, geofences *shipdata.Geofences) *ZoneOverlayElement {
+	return &ZoneOverlayElement{fonts: fonts, geofences: geofences}
+}
+
+func (e *ZoneOverlayElement) ClosestChild(x, y int32) (ChildElement, float64) {
+	return nil, math.MaxFloat64
+}
+
+func (e *ZoneOverlayElement) Render(v *View) error {
+	c := zoneColor
+	if err := v.ScreenRenderer.SetDrawColor(c.R, c.G, c.B, c.A); err != nil {
+		logger.WithError(err).Warn("setting the draw color")
+		return err
+	}
+
+	for _, zone := range e.geofences.Zones() {
+		// close the polygon by returning to the first point
+		sdlPoints := make([]sdl.Point, 0, len(zone.Points)+1)
+		for i := 0; i <= len(zone.Points); i++ {
+			point := zone.Points[i%len(zone.Points)]
+			pos := v.BaseMapPosition(&aislib.PositionReport{Lat: point[0], Lon: point[1]})
+			sdlPoints = append(sdlPoints, sdl.Point{X: int32(pos.X + 0.5), Y: int32(pos.Y + 0.5)})
+		}
+
+		if err := v.ScreenRenderer.DrawLines(sdlPoints); err != nil {
+			logger.WithError(err).Warn("rendering a zone")
+			return err
+		}
+
+		label := sdlPoints[0]
+		if err := e.fonts.RenderText(v, zone.Name, zoneColor, label.X+2, label.Y+2); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
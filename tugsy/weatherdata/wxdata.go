@@ -1,3 +1,85 @@
 package weatherdata
 
-type WeatherData struct{}
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Available returns false for a reading the station didn't report. Those readings are
+// always NaN, so they can't be compared with == or used in arithmetic
+func Available(reading float64) bool {
+	return math.IsNaN(reading) == false
+}
+
+// A StationReport is a set of readings from a single weather station. Readings the
+// station didn't report are NaN
+type StationReport struct {
+	StationID  string
+	Source     string
+	Lat, Lon   float64
+	ObservedAt time.Time
+	ReceivedAt time.Time
+
+	WindSpeedKts      float64
+	WindGustKts       float64
+	WindDirection     float64 // degrees true, the direction the wind is blowing from
+	WindGustDirection float64
+	AirTempC          float64
+	PressureHPa       float64
+	VisibilityNM      float64
+	WaterLevelM       float64 // relative to the station's reference datum
+	CurrentSpeedKts   float64
+	CurrentDirection  float64 // degrees true, the direction the current is flowing towards
+	WaterTempC        float64
+}
+
+type WeatherData struct {
+	sync.Mutex
+
+	stations map[string]*StationReport
+}
+
+func NewWeatherData() *WeatherData {
+	return &WeatherData{stations: make(map[string]*StationReport)}
+}
+
+// UpdateStation replaces the latest report from the report's station
+func (wx *WeatherData) UpdateStation(report *StationReport) {
+	wx.Lock()
+	defer wx.Unlock()
+	wx.stations[report.StationID] = report
+}
+
+// Stations returns a copy of the slice of the latest reports from every station
+func (wx *WeatherData) Stations() []*StationReport {
+	wx.Lock()
+	defer wx.Unlock()
+
+	reports := make([]*StationReport, 0, len(wx.stations))
+	for _, report := range wx.stations {
+		reports = append(reports, report)
+	}
+
+	return reports
+}
+
+// Returns the latest report from the given station, or nil/false if it hasn't reported
+func (wx *WeatherData) Station(id string) (*StationReport, bool) {
+	wx.Lock()
+	defer wx.Unlock()
+	report, ok := wx.stations[id]
+	return report, ok
+}
+
+// PruneStations forgets the stations that haven't reported since the given time
+func (wx *WeatherData) PruneStations(since time.Time) {
+	wx.Lock()
+	defer wx.Unlock()
+
+	for id, report := range wx.stations {
+		if report.ReceivedAt.Before(since) {
+			delete(wx.stations, id)
+		}
+	}
+}